@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/coreos/go-systemd/activation"
@@ -15,7 +16,7 @@ func getVersion() string {
 }
 
 func runDiag(dt api.Dt) {
-	units, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools)
+	units, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools, dt.DtKnowledgeBase)
 	if err != nil {
 		logrus.Fatalf("Error getting units properties: %s", err)
 	}
@@ -24,6 +25,9 @@ func runDiag(dt api.Dt) {
 	for _, unit := range units.Array {
 		if unit.UnitHealth != 0 {
 			fmt.Printf("[%s]: %s %s\n", unit.UnitID, unit.UnitTitle, unit.UnitOutput)
+			if unit.Help != "" {
+				fmt.Println(unit.Help)
+			}
 			fail = true
 		}
 	}
@@ -47,8 +51,15 @@ func main() {
 	}
 
 	DCOSTools := &api.DCOSTools{
-		ExhibitorURL: config.FlagExhibitorClusterStatusURL,
-		ForceTLS:     config.FlagForceTLS,
+		ExhibitorURL:      config.FlagExhibitorClusterStatusURL,
+		ForceTLS:          config.FlagForceTLS,
+		DiscoveryChain:    config.DiscoveryChain,
+		DiscoveryFile:     config.FlagDiscoveryFile,
+		DiscoveryPosition: config.FlagDiscoveryPosition,
+		StaticNodes:       config.StaticNodes,
+
+		DiscoveryCacheTTL:   time.Duration(config.FlagDiscoveryCacheTTLSec) * time.Second,
+		DiscoveryCacheStale: time.Duration(config.FlagDiscoveryCacheStaleSec) * time.Second,
 	}
 
 	// init requester
@@ -62,14 +73,44 @@ func main() {
 		logrus.Errorf("Could not init diagnostics job properly: %s", err)
 	}
 
+	// Load cluster health history, do not hard fail on error
+	healthHistory := &api.HealthHistory{}
+	if err := healthHistory.Init(&config); err != nil {
+		logrus.Errorf("Could not load cluster health history: %s", err)
+	}
+
+	// Load the known cluster nodes, do not hard fail on error
+	nodeTracker := &api.NodeTracker{}
+	if err := nodeTracker.Init(&config); err != nil {
+		logrus.Errorf("Could not load known nodes: %s", err)
+	}
+
+	// Load the remediation knowledge base, do not hard fail on error
+	knowledgeBase := &api.KnowledgeBase{}
+	if err := knowledgeBase.Init(&config); err != nil {
+		logrus.Errorf("Could not load knowledge base: %s", err)
+	}
+
+	// Load the federated clusters, the federation mode is requested explicitly, fail on error
+	var federation *api.Federation
+	if config.FlagFederationConfigFile != "" {
+		federation = &api.Federation{}
+		if err := federation.Init(&config); err != nil {
+			logrus.Fatalf("Could not load federation config: %s", err)
+		}
+	}
+
 	// Inject dependencies used for running 3dt.
 	dt := api.Dt{
-		Cfg:               &config,
-		DtDCOSTools:       DCOSTools,
-		DtDiagnosticsJob:  diagnosticsJob,
-		RunPullerChan:     make(chan bool),
-		RunPullerDoneChan: make(chan bool),
-		SystemdUnits:      &api.SystemdUnits{},
+		Cfg:              &config,
+		DtDCOSTools:      DCOSTools,
+		DtDiagnosticsJob: diagnosticsJob,
+		DtFederation:     federation,
+		DtHealthHistory:  healthHistory,
+		DtKnowledgeBase:  knowledgeBase,
+		DtNodeTracker:    nodeTracker,
+		PullRefresher:    api.NewPullRefresher(),
+		SystemdUnits:     &api.SystemdUnits{},
 	}
 
 	// set verbose (debug) output.
@@ -91,6 +132,16 @@ func main() {
 		go api.StartPullWithInterval(dt)
 	}
 
+	// start pushing a local health report to masters.
+	if config.FlagPush {
+		go api.StartPushWithInterval(dt)
+	}
+
+	// start fetching health reports from the federated clusters.
+	if federation != nil {
+		go api.StartFederationWithInterval(dt)
+	}
+
 	router := api.NewRouter(dt)
 
 	// try using systemd socket
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/dcos/3dt/client"
+)
+
+const (
+	// AggregationAny marks a unit unhealthy if it is unhealthy on at least one node. This is the default policy.
+	AggregationAny = "any"
+
+	// AggregationMajority marks a unit healthy only if it is healthy on a strict majority of the nodes with a role,
+	// e.g. a unit healthy on one of two masters is unhealthy. The role defaults to master.
+	AggregationMajority = "majority"
+
+	// AggregationPercentage marks a unit unhealthy if the percentage of the healthy nodes is below a threshold.
+	AggregationPercentage = "percentage"
+
+	// AggregationLeader takes into account the unit health on the leading master only.
+	AggregationLeader = "leader"
+)
+
+// AggregationPolicy defines how a unit health reported by the cluster nodes is aggregated into the unit
+// cluster health. Policies are set per unit in the aggregation-policies section of a config file.
+type AggregationPolicy struct {
+	Policy  string  `json:"policy"`
+	Role    string  `json:"role,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+}
+
+// unitAggregation shows a policy applied to compute a unit cluster health.
+type unitAggregation = client.UnitAggregation
+
+// unitNodeHealth is a unit health reported by a node.
+type unitNodeHealth struct {
+	node   Node
+	health int
+}
+
+// aggregateUnitHealth sets a unit cluster health based on the unit health on each node and an aggregation policy.
+// If the policy cannot be applied, e.g. the leader does not run the unit, the default policy is used.
+func aggregateUnitHealth(u unit, states []unitNodeHealth, policies map[string]AggregationPolicy) unit {
+	policy, ok := policies[u.UnitName]
+	if !ok {
+		policy = AggregationPolicy{Policy: AggregationAny}
+	}
+	if policy.Policy == AggregationMajority && policy.Role == "" {
+		policy.Role = MasterRole
+	}
+
+	var considered []unitNodeHealth
+	for _, state := range states {
+		switch {
+		case policy.Policy == AggregationLeader && !state.node.Leader:
+			continue
+		case policy.Role != "" && state.node.Role != policy.Role:
+			continue
+		}
+		considered = append(considered, state)
+	}
+
+	if len(considered) == 0 && len(states) > 0 {
+		logrus.Debugf("Could not apply %s aggregation policy to %s, no matching nodes found. Using %s policy",
+			policy.Policy, u.UnitName, AggregationAny)
+		policy = AggregationPolicy{Policy: AggregationAny}
+		considered = states
+	}
+
+	aggregation := &unitAggregation{
+		Policy:  policy.Policy,
+		Role:    policy.Role,
+		Percent: policy.Percent,
+	}
+	var maxHealth int
+	for _, state := range considered {
+		if state.health == 0 {
+			aggregation.Healthy++
+			continue
+		}
+		aggregation.Unhealthy++
+		if state.health > maxHealth {
+			maxHealth = state.health
+		}
+	}
+
+	u.Health = maxHealth
+	total := aggregation.Healthy + aggregation.Unhealthy
+	switch policy.Policy {
+	case AggregationMajority:
+		if aggregation.Healthy > total/2 {
+			u.Health = 0
+		}
+	case AggregationPercentage:
+		if total > 0 && 100*float64(aggregation.Healthy)/float64(total) >= policy.Percent {
+			u.Health = 0
+		}
+	}
+	u.Aggregation = aggregation
+	return u
+}
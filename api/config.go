@@ -40,6 +40,108 @@ var (
 	    "pull": {
 	      "type": "boolean"
 	    },
+	    "coordinated-pull": {
+	      "type": "boolean"
+	    },
+	    "push": {
+	      "type": "boolean"
+	    },
+	    "push-interval": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 3600
+	    },
+	    "push-ttl": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 86400
+	    },
+	    "push-token": {
+	      "type": "string"
+	    },
+	    "discovery-cache-ttl": {
+	      "type": "integer",
+	      "minimum": 0,
+	      "maximum": 3600
+	    },
+	    "discovery-cache-stale": {
+	      "type": "integer",
+	      "minimum": 0,
+	      "maximum": 86400
+	    },
+	    "discovery-file": {
+	      "type": "string"
+	    },
+	    "discovery-position": {
+	      "enum": ["first", "last"]
+	    },
+	    "static-nodes": {
+	      "type": "array",
+	      "items": {
+	        "type": "object",
+	        "properties": {
+	          "ip": {
+	            "type": "string"
+	          },
+	          "role": {
+	            "enum": ["master", "agent", "agent_public"]
+	          },
+	          "leader": {
+	            "type": "boolean"
+	          },
+	          "port": {
+	            "type": "integer",
+	            "minimum": 1,
+	            "maximum": 65535
+	          },
+	          "scheme": {
+	            "enum": ["http", "https"]
+	          },
+	          "labels": {
+	            "type": "object",
+	            "additionalProperties": {
+	              "type": "string"
+	            }
+	          }
+	        },
+	        "required": ["ip", "role"],
+	        "additionalProperties": false
+	      }
+	    },
+	    "discovery-chain": {
+	      "type": "object",
+	      "properties": {
+	        "masters": {
+	          "$ref": "#/definitions/discoveryProviders"
+	        },
+	        "agents": {
+	          "$ref": "#/definitions/discoveryProviders"
+	        }
+	      },
+	      "additionalProperties": false
+	    },
+	    "federation-config": {
+	      "type": "string"
+	    },
+	    "knowledge-base": {
+	      "type": "string"
+	    },
+	    "known-nodes-file": {
+	      "type": "string"
+	    },
+	    "history-file": {
+	      "type": "string"
+	    },
+	    "history-resolution": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 1440
+	    },
+	    "history-retention": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 400
+	    },
 	    "master-port": {
 	      "type": "integer",
 	      "minimum": 1,
@@ -76,9 +178,19 @@ var (
 	      "minimum": 1,
 	      "maximum": 60
 	    },
+	    "tls-mode": {
+	      "enum": ["off", "prefer", "force"]
+	    },
 	    "force-tls": {
 	      "type": "boolean"
 	    },
+	    "summary-policy": {
+	      "enum": ["any", "masters", "none"]
+	    },
+	    "summary-max-age": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
 	    "command-exec-timeout": {
 	      "type": "integer",
 	      "minimum": 1,
@@ -106,9 +218,74 @@ var (
 	    },
 	    "debug": {
 	      "type": "boolean"
+	    },
+	    "aggregation-policies": {
+	      "type": "object",
+	      "additionalProperties": {
+	        "type": "object",
+	        "properties": {
+	          "policy": {
+	            "enum": ["any", "majority", "percentage", "leader"]
+	          },
+	          "role": {
+	            "enum": ["master", "agent", "agent_public"]
+	          },
+	          "percent": {
+	            "type": "number",
+	            "minimum": 0,
+	            "maximum": 100
+	          }
+	        },
+	        "required": ["policy"],
+	        "anyOf": [
+	          {"properties": {"policy": {"not": {"enum": ["percentage"]}}}},
+	          {"required": ["percent"]}
+	        ],
+	        "additionalProperties": false
+	      }
 	    }
 	  },
-	  "additionalProperties": false
+	  "additionalProperties": false,
+	  "definitions": {
+	    "discoveryProviders": {
+	      "type": "array",
+	      "items": {
+	        "type": "object",
+	        "properties": {
+	          "name": {
+	            "enum": ["exhibitor", "dns", "srv", "history", "static"]
+	          },
+	          "url": {
+	            "type": "string"
+	          },
+	          "dns-record": {
+	            "type": "string"
+	          },
+	          "port": {
+	            "type": "integer",
+	            "minimum": 1,
+	            "maximum": 65535
+	          },
+	          "scheme": {
+	            "enum": ["http", "https"]
+	          },
+	          "path": {
+	            "type": "string"
+	          },
+	          "past-time": {
+	            "enum": ["minute", "hour"]
+	          },
+	          "timeout": {
+	            "type": "integer",
+	            "minimum": 1,
+	            "maximum": 60
+	          }
+	        },
+	        "required": ["name"],
+	        "additionalProperties": false
+	      }
+	    }
+	  }
 	}`
 )
 
@@ -127,6 +304,21 @@ type Config struct {
 	// 3dt flags
 	FlagCACertFile                 string `json:"ca-cert"`
 	FlagPull                       bool   `json:"pull"`
+	FlagCoordinatedPull            bool   `json:"coordinated-pull"`
+	FlagPush                       bool   `json:"push"`
+	FlagPushInterval               int    `json:"push-interval"`
+	FlagPushTTL                    int    `json:"push-ttl"`
+	FlagPushToken                  string `json:"push-token"`
+	FlagDiscoveryCacheTTLSec       int    `json:"discovery-cache-ttl"`
+	FlagDiscoveryCacheStaleSec     int    `json:"discovery-cache-stale"`
+	FlagDiscoveryFile              string `json:"discovery-file"`
+	FlagDiscoveryPosition          string `json:"discovery-position"`
+	FlagFederationConfigFile       string `json:"federation-config"`
+	FlagKnowledgeBaseFile          string `json:"knowledge-base"`
+	FlagKnownNodesFile             string `json:"known-nodes-file"`
+	FlagHistoryFile                string `json:"history-file"`
+	FlagHistoryResolutionMinutes   int    `json:"history-resolution"`
+	FlagHistoryRetentionDays       int    `json:"history-retention"`
 	FlagDiag                       bool   `json:"-"`
 	FlagVerbose                    bool   `json:"verbose"`
 	FlagVersion                    bool   `json:"-"`
@@ -138,6 +330,9 @@ type Config struct {
 	FlagUpdateHealthReportInterval int    `json:"health-update-interval"`
 	FlagExhibitorClusterStatusURL  string `json:"exhibitor-ip"`
 	FlagForceTLS                   bool   `json:"force-tls"`
+	FlagTLSMode                    string `json:"tls-mode"`
+	FlagSummaryPolicy              string `json:"summary-policy"`
+	FlagSummaryMaxAgeSec           int    `json:"summary-max-age"`
 	FlagDebug                      bool   `json:"debug"`
 
 	// diagnostics job flags
@@ -147,6 +342,15 @@ type Config struct {
 	FlagDiagnosticsJobTimeoutMinutes             int    `json:"diagnostics-job-timeout"`
 	FlagDiagnosticsJobGetSingleURLTimeoutMinutes int    `json:"diagnostics-url-timeout"`
 	FlagCommandExecTimeoutSec                    int    `json:"command-exec-timeout"`
+
+	// per unit aggregation policies, available in a config file only.
+	AggregationPolicies map[string]AggregationPolicy `json:"aggregation-policies,omitempty"`
+
+	// static nodes used by the discovery, available in a config file only.
+	StaticNodes []StaticNode `json:"static-nodes,omitempty"`
+
+	// discovery providers order and settings, available in a config file only.
+	DiscoveryChain *DiscoveryChain `json:"discovery-chain,omitempty"`
 }
 
 func (c *Config) setFlags(fs *flag.FlagSet) {
@@ -167,6 +371,32 @@ func (c *Config) setFlags(fs *flag.FlagSet) {
 
 	// 3dt flags
 	fs.BoolVar(&c.FlagPull, "pull", c.FlagPull, "Try to pull checks from DC/OS hosts.")
+	fs.BoolVar(&c.FlagCoordinatedPull, "coordinated-pull", c.FlagCoordinatedPull,
+		"Pull checks on the leading master only, other masters serve the leader's report.")
+	fs.BoolVar(&c.FlagPush, "push", c.FlagPush, "Push a local health report to DC/OS masters.")
+	fs.IntVar(&c.FlagPushInterval, "push-interval", c.FlagPushInterval, "Set push interval in seconds.")
+	fs.IntVar(&c.FlagPushTTL, "push-ttl", c.FlagPushTTL, "Expire pushed health reports after seconds.")
+	fs.StringVar(&c.FlagPushToken, "push-token", c.FlagPushToken, "Set a cluster wide token to push health reports.")
+	fs.IntVar(&c.FlagDiscoveryCacheTTLSec, "discovery-cache-ttl", c.FlagDiscoveryCacheTTLSec,
+		"Cache discovered nodes for seconds. 0 disables the cache.")
+	fs.IntVar(&c.FlagDiscoveryCacheStaleSec, "discovery-cache-stale", c.FlagDiscoveryCacheStaleSec,
+		"Serve expired discovered nodes for seconds while they are refreshed in the background.")
+	fs.StringVar(&c.FlagDiscoveryFile, "discovery-file", c.FlagDiscoveryFile,
+		"Discover nodes listed in a JSON file. The file is read again when it changes.")
+	fs.StringVar(&c.FlagDiscoveryPosition, "discovery-position", c.FlagDiscoveryPosition,
+		"Use static nodes first or last in the discovery chain. Must be first or last.")
+	fs.StringVar(&c.FlagFederationConfigFile, "federation-config", c.FlagFederationConfigFile,
+		"Fetch health reports from DC/OS clusters listed in a federation config file.")
+	fs.StringVar(&c.FlagKnowledgeBaseFile, "knowledge-base", c.FlagKnowledgeBaseFile,
+		"Show remediation from a JSON knowledge base file in the unit help. The file is read again when it changes.")
+	fs.StringVar(&c.FlagKnownNodesFile, "known-nodes-file", c.FlagKnownNodesFile,
+		"Persist the cluster nodes seen by 3dt to a file. Empty value keeps the nodes in memory.")
+	fs.StringVar(&c.FlagHistoryFile, "history-file", c.FlagHistoryFile,
+		"Persist cluster health history to a file. Empty value keeps the history in memory.")
+	fs.IntVar(&c.FlagHistoryResolutionMinutes, "history-resolution", c.FlagHistoryResolutionMinutes,
+		"Set cluster health history resolution in minutes.")
+	fs.IntVar(&c.FlagHistoryRetentionDays, "history-retention", c.FlagHistoryRetentionDays,
+		"Set cluster health history retention in days.")
 	fs.IntVar(&c.FlagPullInterval, "pull-interval", c.FlagPullInterval, "Set pull interval in seconds.")
 	fs.IntVar(&c.FlagPullTimeoutSec, "pull-timeout", c.FlagPullTimeoutSec, "Set pull timeout.")
 	fs.IntVar(&c.FlagUpdateHealthReportInterval, "health-update-interval", c.FlagUpdateHealthReportInterval,
@@ -174,6 +404,12 @@ func (c *Config) setFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.FlagExhibitorClusterStatusURL, "exhibitor-ip", c.FlagExhibitorClusterStatusURL,
 		"Use Exhibitor IP address to discover master nodes.")
 	fs.BoolVar(&c.FlagForceTLS, "force-tls", c.FlagForceTLS, "Use HTTPS to do all requests.")
+	fs.StringVar(&c.FlagTLSMode, "tls-mode", c.FlagTLSMode,
+		"Set the scheme used to connect to cluster nodes. Must be off, prefer or force. -force-tls overrides the mode.")
+	fs.StringVar(&c.FlagSummaryPolicy, "summary-policy", c.FlagSummaryPolicy,
+		"Set when the health summary responds with 503. Must be any, masters or none.")
+	fs.IntVar(&c.FlagSummaryMaxAgeSec, "summary-max-age", c.FlagSummaryMaxAgeSec,
+		"Respond to the health summary with 503 if the health report is older than seconds. 0 disables the check.")
 	fs.BoolVar(&c.FlagDebug, "debug", c.FlagDebug, "Enable pprof debugging endpoints.")
 
 	// diagnostics job flags
@@ -210,11 +446,39 @@ func LoadDefaultConfig(args []string) (config Config, err error) {
 	// Set default pull timeout to 3 seconds
 	config.FlagPullTimeoutSec = 3
 
+	// default push interval is 60 seconds, pushed reports expire after 3 missed pushes.
+	config.FlagPushInterval = 60
+	config.FlagPushTTL = 180
+
+	// keep hourly cluster health history for 35 days to cover monthly reports.
+	config.FlagHistoryFile = "/var/lib/dcos/3dt/health-history.json"
+	config.FlagHistoryResolutionMinutes = 60
+	config.FlagHistoryRetentionDays = 35
+
+	// remember the cluster nodes to detect the missing nodes after a restart.
+	config.FlagKnownNodesFile = "/var/lib/dcos/3dt/known-nodes.json"
+
+	// static nodes are the last discovery fallback
+	config.FlagDiscoveryPosition = DiscoveryLast
+
+	// discovered nodes are cached for 30 seconds and served for 5 more minutes while they are refreshed.
+	config.FlagDiscoveryCacheTTLSec = 30
+	config.FlagDiscoveryCacheStaleSec = 300
+
 	config.Version = Version
 	config.Revision = Revision
 
 	config.FlagExhibitorClusterStatusURL = "http://127.0.0.1:8181/exhibitor/v1/cluster/status"
 
+	// connect to the cluster nodes with http unless -force-tls is set.
+	config.FlagTLSMode = TLSModeOff
+
+	// the health summary fails if a unit on a master is unhealthy.
+	config.FlagSummaryPolicy = SummaryPolicyMasters
+
+	// the health summary fails if the report was not updated for 5 pull intervals.
+	config.FlagSummaryMaxAgeSec = 300
+
 	// diagnostics job default flag values
 	config.FlagDiagnosticsBundleDir = "/var/run/dcos/3dt/diagnostic_bundles"
 	config.FlagDiagnosticsJobTimeoutMinutes = 720 //12 hours
@@ -59,3 +59,60 @@ func TestPortMaximum(t *testing.T) {
 		t.Error("Test must fail, but it didn't: %s")
 	}
 }
+
+// Test aggregation policies
+func TestAggregationPolicies(t *testing.T) {
+	userConfig := `
+	{
+	  "aggregation-policies": {
+	    "dcos-exhibitor.service": {"policy": "majority", "role": "master"},
+	    "dcos-marathon.service": {"policy": "leader"},
+	    "dcos-spartan.service": {"policy": "percentage", "percent": 90}
+	  }
+	}
+	`
+	documentLoader := gojsonschema.NewStringLoader(userConfig)
+	if err := validate(documentLoader); err != nil {
+		t.Error(err)
+	}
+
+	for _, policy := range []string{
+		`{"policy": "percentage"}`,
+		`{"policy": "quorum"}`,
+		`{"policy": "any", "role": "slave"}`,
+		`{"policy": "percentage", "percent": 101}`,
+	} {
+		documentLoader = gojsonschema.NewStringLoader(`{"aggregation-policies": {"dcos-test.service": ` + policy + `}}`)
+		if err := validate(documentLoader); err == nil {
+			t.Errorf("Test must fail, but it didn't: %s", policy)
+		}
+	}
+}
+
+// Test discovery chain
+func TestDiscoveryChain(t *testing.T) {
+	userConfig := `
+	{
+	  "discovery-chain": {
+	    "masters": [{"name": "static"}, {"name": "exhibitor", "url": "http://10.0.0.1:8181/exhibitor/v1/cluster/status", "timeout": 5}],
+	    "agents": [{"name": "dns", "dns-record": "leader.mesos", "port": 5050, "timeout": 2}, {"name": "history", "past-time": "hour"}]
+	  }
+	}
+	`
+	documentLoader := gojsonschema.NewStringLoader(userConfig)
+	if err := validate(documentLoader); err != nil {
+		t.Error(err)
+	}
+
+	for _, provider := range []string{
+		`{"name": "zookeeper"}`,
+		`{"name": "dns", "timeout": 0}`,
+		`{"name": "history", "past-time": "day"}`,
+		`{"name": "static", "labels": {}}`,
+	} {
+		documentLoader = gojsonschema.NewStringLoader(`{"discovery-chain": {"masters": [` + provider + `]}}`)
+		if err := validate(documentLoader); err == nil {
+			t.Errorf("Test must fail, but it didn't: %s", provider)
+		}
+	}
+}
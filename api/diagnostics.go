@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"github.com/dcos/3dt/client"
 	"github.com/shirou/gopsutil/disk"
 	"io"
 	"io/ioutil"
@@ -47,48 +48,15 @@ type DiagnosticsJob struct {
 }
 
 // diagnostics job response format
-type diagnosticsReportResponse struct {
-	ResponseCode int      `json:"response_http_code"`
-	Version      int      `json:"version"`
-	Status       string   `json:"status"`
-	Errors       []string `json:"errors"`
-}
+type diagnosticsReportResponse = client.DiagnosticsResponse
 
-type createResponse struct {
-	diagnosticsReportResponse
-	Extra struct {
-		LastBundleFile string `json:"bundle_name"`
-	} `json:"extra"`
-}
+type createResponse = client.CreateResponse
 
 // diagnostics job status format
-type bundleReportStatus struct {
-	// job related fields
-	Running               bool     `json:"is_running"`
-	Status                string   `json:"status"`
-	Errors                []string `json:"errors"`
-	LastBundlePath        string   `json:"last_bundle_dir"`
-	JobStarted            string   `json:"job_started"`
-	JobEnded              string   `json:"job_ended"`
-	JobDuration           string   `json:"job_duration"`
-	JobProgressPercentage float32  `json:"job_progress_percentage"`
-
-	// config related fields
-	DiagnosticBundlesBaseDir                 string `json:"diagnostics_bundle_dir"`
-	DiagnosticsJobTimeoutMin                 int    `json:"diagnostics_job_timeout_min"`
-	DiagnosticsUnitsLogsSinceHours           string `json:"journald_logs_since_hours"`
-	DiagnosticsJobGetSingleURLTimeoutMinutes int    `json:"diagnostics_job_get_since_url_timeout_min"`
-	CommandExecTimeoutSec                    int    `json:"command_exec_timeout_sec"`
-
-	// metrics related
-	DiskUsedPercent float64 `json:"diagnostics_partition_disk_usage_percent"`
-}
+type bundleReportStatus = client.BundleStatus
 
 // Create a bundle request structure, example:   {"nodes": ["all"]}
-type bundleCreateRequest struct {
-	Version int
-	Nodes   []string
-}
+type bundleCreateRequest = client.CreateRequest
 
 // start a diagnostics job
 func (j *DiagnosticsJob) run(req bundleCreateRequest, config *Config, DCOSTools DCOSHelper) (createResponse, error) {
@@ -106,12 +74,12 @@ func (j *DiagnosticsJob) run(req bundleCreateRequest, config *Config, DCOSTools
 		return prepareCreateResponseWithErr(http.StatusServiceUnavailable, err)
 	}
 	if isRunning {
-		return prepareCreateResponseWithErr(http.StatusServiceUnavailable, errors.New("Job is already running"))
+		return prepareCreateResponseWithErr(http.StatusConflict, conflictError("Job is already running"))
 	}
 
 	foundNodes, err := findRequestedNodes(req.Nodes, DCOSTools)
 	if err != nil {
-		return prepareCreateResponseWithErr(http.StatusServiceUnavailable, err)
+		return prepareCreateResponseWithErr(errorStatusCodeOr(err, http.StatusServiceUnavailable), err)
 	}
 	log.Debugf("Found requested nodes: %s", foundNodes)
 
@@ -242,8 +210,7 @@ func (j *DiagnosticsJob) runBackgroundJob(nodes []Node, config *Config, DCOSTool
 	// we already checked for nodes length, we should not get division by zero error at this point.
 	percentPerNode := 100.0 / float32(len(nodes))
 	for _, node := range nodes {
-		port, err := getPullPortByRole(config, node.Role)
-		if err != nil {
+		if _, err := getNodePort(config, node); err != nil {
 			log.Errorf("Used incorrect role: %s", err)
 			j.Errors = append(j.Errors, err.Error())
 			updateSummaryReport("Used incorrect role", node, err.Error(), summaryErrorsReport)
@@ -252,9 +219,18 @@ func (j *DiagnosticsJob) runBackgroundJob(nodes []Node, config *Config, DCOSTool
 		}
 
 		updateSummaryReport("START collecting logs", node, "", summaryReport)
-		url := fmt.Sprintf("http://%s:%d%s/logs", node.IP, port, BaseRoute)
+		var (
+			url        string
+			body       []byte
+			statusCode int
+		)
 		endpoints := make(map[string]string)
-		body, statusCode, err := DCOSTools.Get(url, time.Duration(time.Second*3))
+		scheme, err := doNodeRequest(config, node, func(baseURL string) (err error) {
+			url = baseURL + BaseRoute + "/logs"
+			body, statusCode, err = DCOSTools.Get(url, time.Duration(time.Second*3))
+			return err
+		})
+		node.NegotiatedScheme = scheme
 		if err != nil {
 			errMsg := fmt.Sprintf("could not get a list of logs, url: %s, status code %d", url, statusCode)
 			j.Errors = append(j.Errors, errMsg)
@@ -311,7 +287,7 @@ func (j *DiagnosticsJob) runBackgroundJob(nodes []Node, config *Config, DCOSTool
 // delete a bundle
 func (j *DiagnosticsJob) delete(bundleName string, config *Config, DCOSTools DCOSHelper) (response diagnosticsReportResponse, err error) {
 	if !strings.HasPrefix(bundleName, "bundle-") || !strings.HasSuffix(bundleName, ".zip") {
-		return prepareResponseWithErr(http.StatusServiceUnavailable, errors.New("format allowed  bundle-*.zip"))
+		return prepareResponseWithErr(http.StatusBadRequest, invalidArgumentError("format allowed  bundle-*.zip"))
 	}
 
 	j.Lock()
@@ -335,18 +311,15 @@ func (j *DiagnosticsJob) delete(bundleName string, config *Config, DCOSTools DCO
 		return prepareResponseWithErr(http.StatusServiceUnavailable, err)
 	}
 	if ok {
-		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/delete/%s", node, config.FlagMasterPort, BaseRoute, bundleName)
-		j.Status = "Attempting to delete a bundle on a remote host. POST " + url
-		log.Debug(j.Status)
-		timeout := time.Duration(time.Second * 5)
-		response, _, err := DCOSTools.Post(url, timeout)
+		nodeAPI, err := nodeClient(config, node, DCOSTools, time.Duration(time.Second*5))
 		if err != nil {
 			return prepareResponseWithErr(http.StatusServiceUnavailable, err)
 		}
-		// unmarshal a response from a remote node and return it back.
-		var remoteResponse diagnosticsReportResponse
-		if err = json.Unmarshal(response, &remoteResponse); err != nil {
-			return prepareResponseWithErr(http.StatusServiceUnavailable, err)
+		j.Status = "Attempting to delete a bundle on a remote host " + node.IP
+		log.Debug(j.Status)
+		remoteResponse, err := remoteDiagnosticsResponse(nodeAPI.DeleteBundle(bundleName))
+		if err != nil {
+			return remoteResponse, err
 		}
 		j.Status = remoteResponse.Status
 		return remoteResponse, nil
@@ -389,10 +362,13 @@ func (j *DiagnosticsJob) getStatusAll(config *Config, DCOSTools DCOSHelper) (map
 	}
 
 	for _, master := range masterNodes {
-		var status bundleReportStatus
-		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/status", master.IP, config.FlagMasterPort, BaseRoute)
-		body, _, err := DCOSTools.Get(url, time.Duration(time.Second*3))
-		if err = json.Unmarshal(body, &status); err != nil {
+		nodeAPI, err := nodeClient(config, master, DCOSTools, time.Duration(time.Second*3))
+		if err != nil {
+			log.Errorf("Could not get a URL for node %s: %s", master.IP, err)
+			continue
+		}
+		status, err := nodeAPI.BundleStatus()
+		if err != nil {
 			log.Errorf("Could not determine job status for node %s: %s", master.IP, err)
 			continue
 		}
@@ -442,6 +418,25 @@ func (d diagnosticsJobCanceledError) Error() string {
 	return d.msg
 }
 
+// logEndpointURL returns a URL to fetch a log endpoint from a node. A node lists its endpoints as :port/path, the port
+// is replaced if a discovery provider set a port for the node. The scheme negotiated with the node is used, if the node
+// was not reached yet, the first scheme allowed by the TLS mode is used.
+func logEndpointURL(config *Config, node Node, endpoint string) (string, error) {
+	port, endpointPath := "", endpoint
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		port, endpointPath = endpoint[:i], endpoint[i:]
+	}
+	if node.Port != 0 {
+		port = fmt.Sprintf(":%d", node.Port)
+	}
+
+	scheme := node.NegotiatedScheme
+	if scheme == "" {
+		scheme = nodeSchemesToTry(config, node)[0]
+	}
+	return fmt.Sprintf("%s://%s%s%s", scheme, node.IP, port, endpointPath), nil
+}
+
 // fetch an HTTP endpoint and append the output to a zip file.
 func (j *DiagnosticsJob) getHTTPAddToZip(node Node, endpoints map[string]string, folder string, zipWriter *zip.Writer,
 	summaryErrorsReport, summaryReport *bytes.Buffer, config *Config, DCOSTools DCOSHelper, percentPerNode float32) error {
@@ -452,7 +447,7 @@ func (j *DiagnosticsJob) getHTTPAddToZip(node Node, endpoints map[string]string,
 
 	percentPerURL := percentPerNode / float32(len(endpoints))
 	for fileName, httpEndpoint := range endpoints {
-		fullURL, err := useTLSScheme("http://"+node.IP+httpEndpoint, config.FlagForceTLS)
+		fullURL, err := logEndpointURL(config, node, httpEndpoint)
 		if err != nil {
 			j.Errors = append(j.Errors, err.Error())
 			log.Errorf("Could not read force-tls flag: %s", err)
@@ -556,31 +551,25 @@ func (j *DiagnosticsJob) cancel(config *Config, DCOSTools DCOSHelper) (response
 	// return error if we could not find if the job is running or not.
 	isRunning, node, err := j.isRunning(config, DCOSTools)
 	if err != nil {
-		return response, err
+		return prepareResponseWithErr(http.StatusServiceUnavailable, err)
 	}
 
 	if !isRunning {
-		return prepareResponseWithErr(http.StatusServiceUnavailable, errors.New("Job is not running"))
+		return prepareResponseWithErr(http.StatusConflict, conflictError("Job is not running"))
 	}
 	// if node is empty, try to cancel a job on a localhost
 	if node == "" {
 		j.cancelChan <- true
 		log.Debug("Cancelling a local job")
 	} else {
-		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/cancel", node, config.FlagMasterPort, BaseRoute)
-		j.Status = "Attempting to cancel a job on a remote host. POST " + url
-		log.Debug(j.Status)
-		response, _, err := DCOSTools.Post(url, time.Duration(config.FlagDiagnosticsJobGetSingleURLTimeoutMinutes)*time.Minute)
+		timeout := time.Duration(config.FlagDiagnosticsJobGetSingleURLTimeoutMinutes) * time.Minute
+		nodeAPI, err := nodeClient(config, findMasterNode(node, DCOSTools), DCOSTools, timeout)
 		if err != nil {
 			return prepareResponseWithErr(http.StatusServiceUnavailable, err)
 		}
-		// unmarshal a response from a remote node and return it back.
-		var remoteResponse diagnosticsReportResponse
-		if err = json.Unmarshal(response, &remoteResponse); err != nil {
-			return prepareResponseWithErr(http.StatusServiceUnavailable, err)
-		}
-		return remoteResponse, nil
-
+		j.Status = "Attempting to cancel a job on a remote host " + node
+		log.Debug(j.Status)
+		return remoteDiagnosticsResponse(nodeAPI.CancelBundle())
 	}
 	return prepareResponseOk(http.StatusOK, "Attempting to cancel a job, please check job status.")
 }
@@ -593,50 +582,107 @@ func (j *DiagnosticsJob) stop() {
 	j.Running = false
 }
 
-// get a list of all bundles across the cluster.
-func listAllBundles(config *Config, DCOSTools DCOSHelper) (map[string][]bundle, error) {
-	collectedBundles := make(map[string][]bundle)
+// findMasterNode returns a discovered master node by IP address. If the master is not found, a node with default
+// port and scheme is returned.
+func findMasterNode(ip string, DCOSTools DCOSHelper) Node {
+	masterNodes, err := DCOSTools.GetMasterNodes()
+	if err != nil {
+		log.Errorf("Could not get master nodes: %s", err)
+	}
+	for _, master := range masterNodes {
+		if master.IP == ip {
+			return master
+		}
+	}
+	return Node{IP: ip, Role: MasterRole}
+}
+
+// nodeClient returns a client of the 3dt API on a node. The requests are made with DCOSTools.
+func nodeClient(config *Config, node Node, DCOSTools DCOSHelper, timeout time.Duration) (*client.Client, error) {
+	baseURL, err := nodeBaseURL(config, node)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(baseURL, dcosToolsRequester{DCOSTools}, timeout), nil
+}
+
+// remoteDiagnosticsResponse returns a diagnostics response of a remote node. Error responses of the remote node are
+// returned as is.
+func remoteDiagnosticsResponse(response diagnosticsReportResponse, err error) (diagnosticsReportResponse, error) {
+	if _, ok := err.(*client.Error); ok {
+		return response, nil
+	}
+	if err != nil {
+		return prepareResponseWithErr(http.StatusServiceUnavailable, err)
+	}
+	return response, nil
+}
+
+// masterBundles is a list of bundles available on a master node.
+type masterBundles struct {
+	node    Node
+	port    int
+	bundles []bundle
+}
+
+// listMasterBundles gets a list of bundles from each master node.
+func listMasterBundles(config *Config, DCOSTools DCOSHelper) ([]masterBundles, error) {
+	var collectedBundles []masterBundles
 	masterNodes, err := DCOSTools.GetMasterNodes()
 	if err != nil {
 		return collectedBundles, err
 	}
 	for _, master := range masterNodes {
-		var bundleUrls []bundle
-		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/list", master.IP, config.FlagMasterPort, BaseRoute)
-		body, _, err := DCOSTools.Get(url, time.Duration(time.Second*3))
+		port, err := getNodePort(config, master)
+		if err != nil {
+			log.Errorf("Could not get a port for node %s: %s", master.IP, err)
+			continue
+		}
+		nodeAPI, err := nodeClient(config, master, DCOSTools, time.Duration(time.Second*3))
 		if err != nil {
-			log.Errorf("Could not HTTP GET %s: %s", url, err)
+			log.Errorf("Could not get a URL for node %s: %s", master.IP, err)
 			continue
 		}
-		if err = json.Unmarshal(body, &bundleUrls); err != nil {
-			log.Errorf("Could not unmarshal response from %s: %s", url, err)
+		bundleUrls, err := nodeAPI.ListBundles()
+		if err != nil {
+			log.Errorf("Could not get a list of bundles from node %s: %s", master.IP, err)
 			continue
 		}
-		collectedBundles[fmt.Sprintf("%s:%d", master.IP, config.FlagMasterPort)] = bundleUrls
+		collectedBundles = append(collectedBundles, masterBundles{node: master, port: port, bundles: bundleUrls})
+	}
+	return collectedBundles, nil
+}
+
+// get a list of all bundles across the cluster.
+func listAllBundles(config *Config, DCOSTools DCOSHelper) (map[string][]bundle, error) {
+	collectedBundles := make(map[string][]bundle)
+	masters, err := listMasterBundles(config, DCOSTools)
+	if err != nil {
+		return collectedBundles, err
+	}
+	for _, master := range masters {
+		collectedBundles[fmt.Sprintf("%s:%d", master.node.IP, master.port)] = master.bundles
 	}
 	return collectedBundles, nil
 }
 
-// check if a bundle is available on a cluster.
-func (j *DiagnosticsJob) isBundleAvailable(bundleName string, config *Config, DCOSTools DCOSHelper) (string, string, bool, error) {
-	bundles, err := listAllBundles(config, DCOSTools)
+// check if a bundle is available on a cluster. The function returns a master node with the bundle and the bundle
+// location on the node.
+func (j *DiagnosticsJob) isBundleAvailable(bundleName string, config *Config, DCOSTools DCOSHelper) (Node, string, bool, error) {
+	masters, err := listMasterBundles(config, DCOSTools)
 	if err != nil {
-		return "", "", false, err
+		return Node{}, "", false, err
 	}
 	log.Infof("Trying to find a bundle %s on remote hosts", bundleName)
-	for host, remoteBundles := range bundles {
-		for _, remoteBundle := range remoteBundles {
+	for _, master := range masters {
+		for _, remoteBundle := range master.bundles {
 			if bundleName == path.Base(remoteBundle.File) {
-				log.Infof("Bundle %s found on a host: %s", bundleName, host)
-				hostPort := strings.Split(host, ":")
-				if len(hostPort) > 0 {
-					return hostPort[0], remoteBundle.File, true, nil
-				}
-				return "", "", false, errors.New("Node must be ip:port. Got " + host)
+				log.Infof("Bundle %s found on a host: %s:%d", bundleName, master.node.IP, master.port)
+				return master.node, remoteBundle.File, true, nil
 			}
 		}
 	}
-	return "", "", false, nil
+	return Node{}, "", false, nil
 }
 
 // return a a list of bundles available on a localhost.
@@ -687,7 +733,7 @@ func matchRequestedNodes(requestedNodes []string, masterNodes []Node, agentNodes
 	if len(matchedNodes) > 0 {
 		return matchedNodes, nil
 	}
-	return matchedNodes, fmt.Errorf("Requested nodes: %s not found", requestedNodes)
+	return matchedNodes, notFoundError("Requested nodes: %s not found", requestedNodes)
 }
 
 func findRequestedNodes(requestedNodes []string, DCOSTools DCOSHelper) ([]Node, error) {
@@ -929,14 +975,14 @@ func (j *DiagnosticsJob) dispatchLogs(provider string, entity string, config *Co
 					return r, err
 				}
 				if !canExecute {
-					return r, errors.New("Only DC/OS systemd units are available")
+					return r, forbiddenError("Only DC/OS systemd units are available")
 				}
 				log.Debugf("dispatching a unit %s", entity)
 				r, err = readJournalOutputSince(entity, config.FlagDiagnosticsBundleUnitsLogsSinceString, config.FlagCommandExecTimeoutSec)
 				return r, err
 			}
 		}
-		return r, fmt.Errorf("%s not found", entity)
+		return r, notFoundError("%s not found", entity)
 	}
 
 	if provider == "files" {
@@ -948,14 +994,14 @@ func (j *DiagnosticsJob) dispatchLogs(provider string, entity string, config *Co
 					return r, err
 				}
 				if !canExecute {
-					return r, errors.New("Not allowed to read a file")
+					return r, forbiddenError("Not allowed to read a file")
 				}
 				log.Debugf("Found a file %s", fileProvider.Location)
 				r, err = readFile(fileProvider.Location)
 				return r, err
 			}
 		}
-		return r, errors.New("Not found " + entity)
+		return r, notFoundError("Not found %s", entity)
 	}
 	if provider == "cmds" {
 		log.Debugf("dispatching a command %s", entity)
@@ -966,15 +1012,15 @@ func (j *DiagnosticsJob) dispatchLogs(provider string, entity string, config *Co
 					return r, err
 				}
 				if !canExecute {
-					return r, errors.New("Not allowed to execute a command")
+					return r, forbiddenError("Not allowed to execute a command")
 				}
 				r, err = runCmd(cmdProvider.Command, config.FlagCommandExecTimeoutSec)
 				return r, err
 			}
 		}
-		return r, errors.New("Not found " + entity)
+		return r, notFoundError("Not found %s", entity)
 	}
-	return r, errors.New("Unknown provider " + provider)
+	return r, invalidArgumentError("Unknown provider %s, must be: units, files or cmds", provider)
 }
 
 // the summary report is a file added to a zip bundle file to track any errors occured while collection logs.
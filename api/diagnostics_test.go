@@ -182,7 +182,7 @@ func (s *DiagnosticsTestSuit) TestisSnapshotAvailable() {
 	// should find
 	host, remoteSnapshot, ok, err := s.dt.DtDiagnosticsJob.isBundleAvailable("bundle-2016-05-13T22:11:36.zip", s.dt.Cfg, s.dt.DtDCOSTools)
 	s.assert.True(ok)
-	s.assert.Equal(host, "127.0.0.1")
+	s.assert.Equal(host.IP, "127.0.0.1")
 	s.assert.Equal(remoteSnapshot, "/system/health/v1/report/diagnostics/serve/bundle-2016-05-13T22:11:36.zip")
 	s.assert.Nil(err)
 
@@ -219,17 +219,30 @@ func (s *DiagnosticsTestSuit) TestCancelNotRunningJob() {
 
 	// Job should fail because it is not running
 	response, code := s.http("/system/health/v1/report/diagnostics/cancel", "POST", nil)
-	s.assert.Equal(code, http.StatusServiceUnavailable)
+	s.assert.Equal(code, http.StatusConflict)
 	var responseJSON diagnosticsReportResponse
 	err := json.Unmarshal(response, &responseJSON)
 	s.assert.NoError(err)
+	s.assert.NotEmpty(responseJSON.RequestID)
+	responseJSON.RequestID = ""
 	s.assert.Equal(responseJSON, diagnosticsReportResponse{
 		Version:      1,
 		Status:       "Job is not running",
-		ResponseCode: http.StatusServiceUnavailable,
+		ResponseCode: http.StatusConflict,
+		Code:         errorCodeConflict,
+		Message:      "Job is not running",
 	})
 }
 
+func (s *DiagnosticsTestSuit) TestDeleteBundleIncorrectName() {
+	response, code := s.http("/system/health/v1/report/diagnostics/delete/snapshot.tar", "POST", nil)
+	s.assert.Equal(code, http.StatusBadRequest)
+	var responseJSON diagnosticsReportResponse
+	s.assert.NoError(json.Unmarshal(response, &responseJSON))
+	s.assert.Equal(responseJSON.Code, errorCodeInvalidArgument)
+	s.assert.Equal(responseJSON.Message, "format allowed  bundle-*.zip")
+}
+
 // Test we can cancel a job running on a different node.
 func (s *DiagnosticsTestSuit) TestCancelGlobalJob() {
 
@@ -328,13 +341,14 @@ func (s *DiagnosticsTestSuit) TestFailRunSnapshotJob() {
 	// node should not be found
 	body = bytes.NewBuffer([]byte(`{"nodes": ["192.168.0.1"]}`))
 	response, code := s.http("http://127.0.0.1:1050/system/health/v1/report/diagnostics/create", "POST", body)
-	s.assert.Equal(code, http.StatusServiceUnavailable)
+	s.assert.Equal(code, http.StatusNotFound)
 
 	var responseJSON diagnosticsReportResponse
 	if err := json.Unmarshal(response, &responseJSON); err != nil {
 		s.Assert()
 	}
 	s.assert.Equal(responseJSON.Status, "Requested nodes: [192.168.0.1] not found")
+	s.assert.Equal(responseJSON.Code, errorCodeNotFound)
 }
 
 func (s *DiagnosticsTestSuit) TestRunSnapshot() {
@@ -379,6 +393,30 @@ func (s *DiagnosticsTestSuit) TestRunSnapshot() {
 	s.assert.True(len(snapshotFiles) > 0)
 }
 
+func (s *DiagnosticsTestSuit) TestDispatchLogsErrors() {
+	s.dt.DtDiagnosticsJob.logProviders = &LogProviders{
+		HTTPEndpoints: []HTTPProvider{{Port: 5050, URI: "/state", FileName: "5050:state.json", Role: []string{"agent"}}},
+		LocalFiles:    []FileProvider{{Location: "/var/log/mesos.log", Role: []string{"agent"}, sanitizedLocation: "var_log_mesos.log"}},
+		LocalCommands: []CommandProvider{{Command: []string{"dmesg"}, Role: []string{"agent"}, indexedCommand: "dmesg-0.output"}},
+	}
+
+	for url, expectedCode := range map[string]int{
+		"/system/health/v1/logs/units/dcos-unknown.service": http.StatusNotFound,
+		"/system/health/v1/logs/files/var_log_unknown.log":  http.StatusNotFound,
+		"/system/health/v1/logs/cmds/unknown-0.output":      http.StatusNotFound,
+		"/system/health/v1/logs/units/5050:state.json":      http.StatusForbidden,
+		"/system/health/v1/logs/files/var_log_mesos.log":    http.StatusForbidden,
+		"/system/health/v1/logs/cmds/dmesg-0.output":        http.StatusForbidden,
+		"/system/health/v1/logs/sockets/docker.sock":        http.StatusBadRequest,
+	} {
+		response, code := s.http(url, "GET", nil)
+		s.assert.Equal(expectedCode, code, url)
+		var errResponse errorResponse
+		s.assert.NoError(json.Unmarshal(response, &errResponse), url)
+		s.assert.Equal(statusErrorCode(expectedCode), errResponse.Code, url)
+	}
+}
+
 func TestSnapshotTestSuit(t *testing.T) {
 	suite.Run(t, new(DiagnosticsTestSuit))
 }
@@ -0,0 +1,578 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// DiscoveryFirst uses static nodes before the DC/OS discovery providers.
+	DiscoveryFirst = "first"
+
+	// DiscoveryLast uses static nodes if the DC/OS discovery providers did not find any nodes.
+	DiscoveryLast = "last"
+)
+
+// Discovery providers available in a discovery chain.
+const (
+	// DiscoveryExhibitor finds masters in exhibitor cluster status.
+	DiscoveryExhibitor = "exhibitor"
+
+	// DiscoveryDNS finds masters by resolving a dns record, agents by requesting the leading mesos master.
+	DiscoveryDNS = "dns"
+
+	// DiscoverySRV finds nodes by resolving a dns SRV record, every record target is a node with its own port.
+	DiscoverySRV = "srv"
+
+	// DiscoveryHistory finds agents in the history service state files.
+	DiscoveryHistory = "history"
+
+	// DiscoveryStatic finds nodes in a discovery file and in the static nodes from a config file.
+	DiscoveryStatic = "static"
+)
+
+// DiscoveryProvider is a discovery provider in a discovery chain. Only the fields used by the provider are set,
+// the rest of the fields are ignored.
+type DiscoveryProvider struct {
+	Name       string `json:"name"`
+	URL        string `json:"url,omitempty"`
+	DNSRecord  string `json:"dns-record,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	Scheme     string `json:"scheme,omitempty"`
+	Path       string `json:"path,omitempty"`
+	PastTime   string `json:"past-time,omitempty"`
+	TimeoutSec int    `json:"timeout,omitempty"`
+}
+
+// DiscoveryChain is a list of discovery providers for masters and agents. The providers are tried in order until
+// one of them finds nodes.
+type DiscoveryChain struct {
+	Masters []DiscoveryProvider `json:"masters,omitempty"`
+	Agents  []DiscoveryProvider `json:"agents,omitempty"`
+}
+
+// discoveryTrace shows how nodes with a role were found during the last lookup.
+type discoveryTrace struct {
+	Role     string          `json:"role"`
+	Time     time.Time       `json:"time"`
+	Provider string          `json:"provider,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Stale    bool            `json:"stale,omitempty"`
+	Steps    []discoveryStep `json:"steps"`
+}
+
+// discoveryStep is a result of a single discovery provider lookup.
+type discoveryStep struct {
+	Provider DiscoveryProvider `json:"provider"`
+	Nodes    []string          `json:"nodes,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Duration string            `json:"duration"`
+}
+
+// globalDiscoveryTraces keeps the last discovery trace for each role.
+var globalDiscoveryTraces = &discoveryTraces{
+	traces: make(map[string]discoveryTrace),
+}
+
+type discoveryTraces struct {
+	sync.RWMutex
+	traces map[string]discoveryTrace
+}
+
+func (d *discoveryTraces) set(trace discoveryTrace) {
+	d.Lock()
+	defer d.Unlock()
+	d.traces[trace.Role] = trace
+}
+
+// markStale marks the last trace for a role, the last known nodes were used because the discovery failed.
+func (d *discoveryTraces) markStale(role string) {
+	d.Lock()
+	defer d.Unlock()
+	if trace, ok := d.traces[role]; ok {
+		trace.Stale = true
+		d.traces[role] = trace
+	}
+}
+
+func (d *discoveryTraces) get() map[string]discoveryTrace {
+	d.RLock()
+	defer d.RUnlock()
+	traces := make(map[string]discoveryTrace)
+	for role, trace := range d.traces {
+		traces[role] = trace
+	}
+	return traces
+}
+
+// StaticNode is a node entry in a discovery file or in the static-nodes section of a config file.
+type StaticNode struct {
+	IP     string            `json:"ip"`
+	Role   string            `json:"role"`
+	Leader bool              `json:"leader,omitempty"`
+	Port   int               `json:"port,omitempty"`
+	Scheme string            `json:"scheme,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type discoveryFile struct {
+	Nodes []StaticNode `json:"nodes"`
+}
+
+// discoveryFiles keeps the nodes read from discovery files. A file is read again when its modification time changes,
+// so the nodes can be updated without restarting 3dt.
+var discoveryFiles = &discoveryFileCache{
+	files: make(map[string]discoveryFileEntry),
+}
+
+type discoveryFileCache struct {
+	sync.Mutex
+	files map[string]discoveryFileEntry
+}
+
+type discoveryFileEntry struct {
+	modTime time.Time
+	nodes   []StaticNode
+}
+
+func (c *discoveryFileCache) get(path string) ([]StaticNode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if entry, ok := c.files[path]; ok && entry.modTime.Equal(info.ModTime()) {
+		return entry.nodes, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var df discoveryFile
+	if err := json.Unmarshal(content, &df); err != nil {
+		return nil, fmt.Errorf("could not parse discovery file %s: %s", path, err)
+	}
+	for _, node := range df.Nodes {
+		if node.IP == "" {
+			return nil, fmt.Errorf("node ip must be set in discovery file %s", path)
+		}
+		if _, err := getPullPortByRole(&Config{}, node.Role); err != nil {
+			return nil, fmt.Errorf("node %s in discovery file %s: %s", node.IP, path, err)
+		}
+		if node.Scheme != "" && node.Scheme != "http" && node.Scheme != "https" {
+			return nil, fmt.Errorf("node %s in discovery file %s: scheme must be http or https", node.IP, path)
+		}
+	}
+
+	logrus.Infof("Loaded %d nodes from discovery file %s", len(df.Nodes), path)
+	c.files[path] = discoveryFileEntry{
+		modTime: info.ModTime(),
+		nodes:   df.Nodes,
+	}
+	return df.Nodes, nil
+}
+
+// find nodes in a discovery file or in a static list of nodes
+type findNodesInFile struct {
+	path   string
+	static []StaticNode
+	role   string
+	next   nodeFinder
+}
+
+func (f *findNodesInFile) getNodes() (nodes []Node, err error) {
+	staticNodes := f.static
+	if f.path != "" {
+		fileNodes, err := discoveryFiles.get(f.path)
+		if err != nil {
+			return nodes, err
+		}
+		staticNodes = append(fileNodes[:len(fileNodes):len(fileNodes)], staticNodes...)
+	}
+
+	for _, node := range staticNodes {
+		// agents are looked up with public agents.
+		if node.Role != f.role && !(f.role == AgentRole && node.Role == AgentPublicRole) {
+			continue
+		}
+		nodes = append(nodes, Node{
+			Role:   node.Role,
+			IP:     node.IP,
+			Leader: node.Leader,
+			Port:   node.Port,
+			Scheme: node.Scheme,
+			Labels: node.Labels,
+		})
+	}
+
+	if len(nodes) == 0 {
+		return nodes, NodesNotFoundError{
+			msg: fmt.Sprintf("%s nodes were not found in static nodes", f.role),
+		}
+	}
+	return nodes, nil
+}
+
+func (f *findNodesInFile) find() (nodes []Node, err error) {
+	nodes, err = f.getNodes()
+	if err == nil {
+		logrus.Debugf("Found %s nodes in static nodes", f.role)
+		return nodes, nil
+	}
+	// try next provider if it is available
+	if f.next != nil {
+		logrus.Warning(err)
+		return f.next.find()
+	}
+	return nodes, err
+}
+
+// find nodes by resolving a dns SRV record, each record target is a node listening on the record port.
+type findNodesInSRV struct {
+	record  string
+	role    string
+	scheme  string
+	timeout time.Duration
+	next    nodeFinder
+
+	// lookupSRV and lookupHost resolve dns records, net.DefaultResolver is used if not set.
+	lookupSRV  func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+func (f *findNodesInSRV) getNodes() (nodes []Node, err error) {
+	lookupSRV, lookupHost := f.lookupSRV, f.lookupHost
+	if lookupSRV == nil {
+		lookupSRV = net.DefaultResolver.LookupSRV
+	}
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+
+	timeout := f.timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, records, err := lookupSRV(ctx, "", "", f.record)
+	if err != nil {
+		return nodes, err
+	}
+
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		ip := target
+		if net.ParseIP(target) == nil {
+			ips, err := lookupHost(ctx, target)
+			if err != nil || len(ips) == 0 {
+				logrus.Warningf("Could not resolve SRV record %s target %s: %v", f.record, target, err)
+				continue
+			}
+			ip = ips[0]
+		}
+		nodes = append(nodes, Node{
+			Role:   f.role,
+			IP:     ip,
+			Host:   target,
+			Port:   int(record.Port),
+			Scheme: f.scheme,
+		})
+	}
+
+	if len(nodes) == 0 {
+		return nodes, NodesNotFoundError{
+			msg: fmt.Sprintf("%s nodes were not found in SRV record %s", f.role, f.record),
+		}
+	}
+	return nodes, nil
+}
+
+func (f *findNodesInSRV) find() (nodes []Node, err error) {
+	nodes, err = f.getNodes()
+	if err == nil {
+		logrus.Debugf("Found %s nodes by resolving SRV record %s", f.role, f.record)
+		return nodes, nil
+	}
+	// try next provider if it is available
+	if f.next != nil {
+		logrus.Warning(err)
+		return f.next.find()
+	}
+	return nodes, err
+}
+
+// discover finds nodes with a role by trying the discovery providers in order until one of them finds the nodes.
+// Every lookup is recorded in globalDiscoveryTraces.
+func (st *DCOSTools) discover(role string) ([]Node, error) {
+	trace := discoveryTrace{
+		Role: role,
+		Time: time.Now(),
+	}
+	defer func() {
+		globalDiscoveryTraces.set(trace)
+	}()
+
+	var lastErr error
+	for _, provider := range st.discoveryProviders(role) {
+		step := discoveryStep{Provider: provider}
+		start := time.Now()
+		nodes, err := st.findWithProvider(role, provider)
+		step.Duration = time.Since(start).String()
+		if err != nil {
+			logrus.Warningf("Could not find %s nodes with %s discovery provider: %s", role, provider.Name, err)
+			step.Error = err.Error()
+			trace.Steps = append(trace.Steps, step)
+			lastErr = err
+			continue
+		}
+
+		for _, node := range nodes {
+			step.Nodes = append(step.Nodes, node.IP)
+		}
+		trace.Steps = append(trace.Steps, step)
+		trace.Provider = provider.Name
+		return nodes, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no discovery providers configured for %s nodes", role)
+	}
+	trace.Error = lastErr.Error()
+	return nil, lastErr
+}
+
+// findWithProvider finds nodes with a single discovery provider.
+func (st *DCOSTools) findWithProvider(role string, provider DiscoveryProvider) ([]Node, error) {
+	var finder nodeFinder
+	timeout := time.Duration(provider.TimeoutSec) * time.Second
+	switch provider.Name {
+	case DiscoveryExhibitor:
+		if role != MasterRole {
+			return nil, fmt.Errorf("%s discovery provider can find %s nodes only", provider.Name, MasterRole)
+		}
+		finder = &findMastersInExhibitor{
+			url:     provider.URL,
+			timeout: timeout,
+			getFn:   st.getExhibitor,
+		}
+	case DiscoveryDNS:
+		finder = &findNodesInDNS{
+			forceTLS:  st.ForceTLS,
+			dnsRecord: provider.DNSRecord,
+			role:      role,
+			port:      provider.Port,
+			timeout:   timeout,
+			getFn:     st.Get,
+		}
+	case DiscoverySRV:
+		if provider.DNSRecord == "" {
+			return nil, errors.New("dns-record must be set for srv discovery provider")
+		}
+		finder = &findNodesInSRV{
+			record:  provider.DNSRecord,
+			role:    role,
+			scheme:  provider.Scheme,
+			timeout: timeout,
+		}
+	case DiscoveryHistory:
+		if role != AgentRole {
+			return nil, fmt.Errorf("%s discovery provider can find %s nodes only", provider.Name, AgentRole)
+		}
+		finder = &findAgentsInHistoryService{
+			basePath: provider.Path,
+			pastTime: "/" + provider.PastTime + "/",
+		}
+	case DiscoveryStatic:
+		finder = &findNodesInFile{
+			path:   provider.Path,
+			static: st.StaticNodes,
+			role:   role,
+		}
+	default:
+		return nil, fmt.Errorf("unknown discovery provider %s", provider.Name)
+	}
+	return finder.find()
+}
+
+// discoveryProviders returns the discovery chain for a role with default values set. If the chain is not configured,
+// the default DC/OS chain is used with static nodes at the configured position.
+func (st *DCOSTools) discoveryProviders(role string) []DiscoveryProvider {
+	var providers []DiscoveryProvider
+	if st.DiscoveryChain != nil {
+		providers = st.DiscoveryChain.Masters
+		if role != MasterRole {
+			providers = st.DiscoveryChain.Agents
+		}
+	}
+
+	if len(providers) == 0 {
+		providers = []DiscoveryProvider{{Name: DiscoveryExhibitor}, {Name: DiscoveryDNS}}
+		if role != MasterRole {
+			providers = []DiscoveryProvider{
+				{Name: DiscoveryDNS},
+				{Name: DiscoveryHistory, PastTime: "minute"},
+				{Name: DiscoveryHistory, PastTime: "hour"},
+			}
+		}
+
+		if st.DiscoveryFile != "" || len(st.StaticNodes) > 0 {
+			static := DiscoveryProvider{Name: DiscoveryStatic}
+			if st.DiscoveryPosition == DiscoveryFirst {
+				providers = append([]DiscoveryProvider{static}, providers...)
+			} else {
+				providers = append(providers, static)
+			}
+		}
+	}
+
+	result := make([]DiscoveryProvider, len(providers))
+	for i, provider := range providers {
+		result[i] = st.withDefaults(role, provider)
+	}
+	return result
+}
+
+// withDefaults sets default values of a discovery provider.
+func (st *DCOSTools) withDefaults(role string, provider DiscoveryProvider) DiscoveryProvider {
+	switch provider.Name {
+	case DiscoveryExhibitor:
+		if provider.URL == "" {
+			provider.URL = st.ExhibitorURL
+		}
+		if provider.TimeoutSec == 0 {
+			provider.TimeoutSec = 11
+		}
+	case DiscoveryDNS:
+		if provider.DNSRecord == "" {
+			// agents are found by requesting the leading mesos master.
+			provider.DNSRecord = "master.mesos"
+			if role != MasterRole {
+				provider.DNSRecord = "leader.mesos"
+			}
+		}
+		if provider.Port == 0 && role != MasterRole {
+			provider.Port = 5050
+		}
+		if provider.TimeoutSec == 0 {
+			provider.TimeoutSec = 1
+		}
+	case DiscoverySRV:
+		if provider.TimeoutSec == 0 {
+			provider.TimeoutSec = 1
+		}
+	case DiscoveryHistory:
+		if provider.Path == "" {
+			provider.Path = "/var/lib/dcos/dcos-history"
+		}
+		if provider.PastTime == "" {
+			provider.PastTime = "minute"
+		}
+	case DiscoveryStatic:
+		if provider.Path == "" {
+			provider.Path = st.DiscoveryFile
+		}
+	}
+	return provider
+}
+
+// discoveryCache keeps the nodes found by the discovery chain per role. Nodes older than ttl are returned while they
+// are refreshed in the background, nodes older than ttl+stale are refreshed before returning. If the discovery fails,
+// the last known nodes are returned.
+type discoveryCache struct {
+	sync.Mutex
+	entries map[string]*discoveryCacheEntry
+}
+
+type discoveryCacheEntry struct {
+	nodes      []Node
+	updated    time.Time
+	refreshing bool
+}
+
+// copyNodes returns a copy of nodes, so the callers can append to the returned slice.
+func copyNodes(nodes []Node) []Node {
+	return append([]Node(nil), nodes...)
+}
+
+// cachedDiscover finds nodes with a role using the discovery cache.
+func (st *DCOSTools) cachedDiscover(role string) ([]Node, error) {
+	c := &st.discoveryCache
+	c.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*discoveryCacheEntry)
+	}
+	entry, ok := c.entries[role]
+	if ok && st.DiscoveryCacheTTL > 0 {
+		age := time.Since(entry.updated)
+		if age < st.DiscoveryCacheTTL {
+			defer c.Unlock()
+			return copyNodes(entry.nodes), nil
+		}
+
+		if age < st.DiscoveryCacheTTL+st.DiscoveryCacheStale {
+			if !entry.refreshing {
+				entry.refreshing = true
+				logrus.Debugf("Cached %s nodes expired %s ago, refreshing in the background", role, age-st.DiscoveryCacheTTL)
+				go st.refreshDiscoveryCache(role)
+			}
+			defer c.Unlock()
+			return copyNodes(entry.nodes), nil
+		}
+	}
+	c.Unlock()
+
+	return st.refreshDiscoveryCache(role)
+}
+
+// refreshDiscoveryCache runs the discovery chain and updates the cache. If all discovery providers fail, the last
+// known nodes are returned and the discovery trace is marked stale.
+func (st *DCOSTools) refreshDiscoveryCache(role string) ([]Node, error) {
+	nodes, err := st.discover(role)
+
+	c := &st.discoveryCache
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[role]
+	if err != nil {
+		if !ok {
+			return nodes, err
+		}
+		entry.refreshing = false
+		logrus.Warningf("Using last known %s nodes found at %s: %s", role, entry.updated, err)
+		globalDiscoveryTraces.markStale(role)
+		return copyNodes(entry.nodes), nil
+	}
+
+	c.entries[role] = &discoveryCacheEntry{
+		nodes:   copyNodes(nodes),
+		updated: time.Now(),
+	}
+	return nodes, nil
+}
+
+// InvalidateNodesCache removes the cached nodes, the next lookup runs the discovery chain. The last known nodes are
+// kept in case the discovery fails.
+func (st *DCOSTools) InvalidateNodesCache() {
+	c := &st.discoveryCache
+	c.Lock()
+	defer c.Unlock()
+	for _, entry := range c.entries {
+		entry.updated = time.Time{}
+	}
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiscoveryTestSuit struct {
+	suite.Suite
+	assert *assertPackage.Assertions
+	dir    string
+	path   string
+}
+
+func (s *DiscoveryTestSuit) SetupTest() {
+	s.assert = assertPackage.New(s.T())
+
+	var err error
+	s.dir, err = ioutil.TempDir("", "3dt-discovery")
+	s.assert.NoError(err)
+	s.path = filepath.Join(s.dir, "nodes.json")
+}
+
+func (s *DiscoveryTestSuit) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *DiscoveryTestSuit) writeNodes(nodes string, modTime time.Time) {
+	s.assert.NoError(ioutil.WriteFile(s.path, []byte(nodes), 0644))
+	s.assert.NoError(os.Chtimes(s.path, modTime, modTime))
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryFile() {
+	s.writeNodes(`{"nodes": [
+		{"ip": "10.0.0.1", "role": "master", "leader": true},
+		{"ip": "10.0.0.2", "role": "agent", "port": 61001, "labels": {"rack": "r1"}},
+		{"ip": "10.0.0.3", "role": "agent_public"}
+	]}`, time.Now().Add(-time.Hour))
+
+	st := &DCOSTools{DiscoveryFile: s.path, DiscoveryPosition: DiscoveryFirst}
+	masters, err := st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.1", Role: MasterRole, Leader: true}})
+
+	agents, err := st.GetAgentNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(agents, []Node{
+		{IP: "10.0.0.2", Role: AgentRole, Port: 61001, Labels: map[string]string{"rack": "r1"}},
+		{IP: "10.0.0.3", Role: AgentPublicRole},
+	})
+
+	// the file is read again when it changes
+	s.writeNodes(`{"nodes": [{"ip": "10.0.0.4", "role": "master"}]}`, time.Now())
+	masters, err = st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.4", Role: MasterRole}})
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryFileIncorrect() {
+	for _, nodes := range []string{
+		`{"nodes": [{"role": "master"}]}`,
+		`{"nodes": [{"ip": "10.0.0.1", "role": "slave"}]}`,
+		`nodes`,
+	} {
+		s.writeNodes(nodes, time.Now())
+		_, err := discoveryFiles.get(s.path)
+		s.assert.Error(err, nodes)
+	}
+}
+
+func (s *DiscoveryTestSuit) TestStaticNodesFallback() {
+	last := &findNodesInFile{
+		static: []StaticNode{{IP: "10.0.0.1", Role: MasterRole}},
+		role:   MasterRole,
+	}
+	finder := &findNodesInFile{
+		path: filepath.Join(s.dir, "not-found.json"),
+		role: MasterRole,
+		next: last,
+	}
+	nodes, err := finder.find()
+	s.assert.NoError(err)
+	s.assert.Equal(nodes, []Node{{IP: "10.0.0.1", Role: MasterRole}})
+
+	_, err = (&findNodesInFile{static: last.static, role: AgentRole}).find()
+	s.assert.IsType(err, NodesNotFoundError{})
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryProvidersDefault() {
+	st := &DCOSTools{ExhibitorURL: "http://127.0.0.1:8181/exhibitor/v1/cluster/status"}
+	s.assert.Equal(st.discoveryProviders(MasterRole), []DiscoveryProvider{
+		{Name: DiscoveryExhibitor, URL: "http://127.0.0.1:8181/exhibitor/v1/cluster/status", TimeoutSec: 11},
+		{Name: DiscoveryDNS, DNSRecord: "master.mesos", TimeoutSec: 1},
+	})
+	s.assert.Equal(st.discoveryProviders(AgentRole), []DiscoveryProvider{
+		{Name: DiscoveryDNS, DNSRecord: "leader.mesos", Port: 5050, TimeoutSec: 1},
+		{Name: DiscoveryHistory, Path: "/var/lib/dcos/dcos-history", PastTime: "minute"},
+		{Name: DiscoveryHistory, Path: "/var/lib/dcos/dcos-history", PastTime: "hour"},
+	})
+
+	// static nodes are the last fallback by default
+	st.StaticNodes = []StaticNode{{IP: "10.0.0.1", Role: MasterRole}}
+	providers := st.discoveryProviders(MasterRole)
+	s.assert.Len(providers, 3)
+	s.assert.Equal(providers[2], DiscoveryProvider{Name: DiscoveryStatic})
+
+	st.DiscoveryPosition = DiscoveryFirst
+	st.DiscoveryFile = s.path
+	providers = st.discoveryProviders(AgentRole)
+	s.assert.Len(providers, 4)
+	s.assert.Equal(providers[0], DiscoveryProvider{Name: DiscoveryStatic, Path: s.path})
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryChain() {
+	st := &DCOSTools{
+		DiscoveryChain: &DiscoveryChain{
+			Agents: []DiscoveryProvider{
+				{Name: DiscoveryHistory, Path: filepath.Join(s.dir, "history")},
+				{Name: DiscoveryExhibitor},
+				{Name: DiscoveryStatic},
+			},
+		},
+		StaticNodes: []StaticNode{{IP: "10.0.0.2", Role: AgentRole}},
+	}
+	nodes, err := st.GetAgentNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(nodes, []Node{{IP: "10.0.0.2", Role: AgentRole}})
+
+	trace := globalDiscoveryTraces.get()[AgentRole]
+	s.assert.Equal(trace.Provider, DiscoveryStatic)
+	s.assert.Empty(trace.Error)
+	s.assert.Len(trace.Steps, 3)
+	s.assert.Equal(trace.Steps[0].Provider.PastTime, "minute")
+	s.assert.Contains(trace.Steps[0].Error, "no such file or directory")
+	s.assert.Equal(trace.Steps[1].Error, "exhibitor discovery provider can find master nodes only")
+	s.assert.Equal(trace.Steps[2].Nodes, []string{"10.0.0.2"})
+
+	// no masters in the static nodes
+	st.DiscoveryChain.Masters = []DiscoveryProvider{{Name: DiscoveryStatic}}
+	_, err = st.GetMasterNodes()
+	s.assert.Error(err)
+	trace = globalDiscoveryTraces.get()[MasterRole]
+	s.assert.Empty(trace.Provider)
+	s.assert.Equal(trace.Error, "master nodes were not found in static nodes")
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryCache() {
+	s.writeNodes(`{"nodes": [{"ip": "10.0.0.1", "role": "master"}]}`, time.Now().Add(-time.Hour))
+	st := &DCOSTools{
+		DiscoveryChain:    &DiscoveryChain{Masters: []DiscoveryProvider{{Name: DiscoveryStatic, Path: s.path}}},
+		DiscoveryCacheTTL: time.Hour,
+	}
+	masters, err := st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.1", Role: MasterRole}})
+
+	// cached nodes are returned until the cache is invalidated
+	s.writeNodes(`{"nodes": [{"ip": "10.0.0.2", "role": "master"}]}`, time.Now())
+	masters, err = st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.1", Role: MasterRole}})
+
+	st.InvalidateNodesCache()
+	masters, err = st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.2", Role: MasterRole}})
+
+	// the last known nodes are used if the discovery fails
+	s.assert.NoError(os.Remove(s.path))
+	st.InvalidateNodesCache()
+	masters, err = st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.2", Role: MasterRole}})
+	trace := globalDiscoveryTraces.get()[MasterRole]
+	s.assert.True(trace.Stale)
+	s.assert.NotEmpty(trace.Error)
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryCacheStale() {
+	s.writeNodes(`{"nodes": [{"ip": "10.0.0.1", "role": "master"}]}`, time.Now().Add(-time.Hour))
+	st := &DCOSTools{
+		DiscoveryChain:      &DiscoveryChain{Masters: []DiscoveryProvider{{Name: DiscoveryStatic, Path: s.path}}},
+		DiscoveryCacheTTL:   time.Millisecond,
+		DiscoveryCacheStale: time.Hour,
+	}
+	_, err := st.GetMasterNodes()
+	s.assert.NoError(err)
+	time.Sleep(10 * time.Millisecond)
+
+	// expired nodes are returned while they are refreshed in the background
+	s.writeNodes(`{"nodes": [{"ip": "10.0.0.2", "role": "master"}]}`, time.Now())
+	masters, err := st.GetMasterNodes()
+	s.assert.NoError(err)
+	s.assert.Equal(masters, []Node{{IP: "10.0.0.1", Role: MasterRole}})
+
+	s.assert.Eventually(func() bool {
+		st.discoveryCache.Lock()
+		defer st.discoveryCache.Unlock()
+		return st.discoveryCache.entries[MasterRole].nodes[0].IP == "10.0.0.2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *DiscoveryTestSuit) TestMesosAgentNode() {
+	var sr agentsResponse
+	s.assert.NoError(json.Unmarshal([]byte(`{"slaves": [{
+		"id": "a1-S1",
+		"hostname": "10.0.0.3",
+		"registered_time": 1497373938.5,
+		"active": true,
+		"resources": {"cpus": 4, "ports": "[1025-2180]"},
+		"attributes": {"public_ip": "true", "rack": "r1", "zone": 2}
+	}]}`), &sr))
+	s.assert.Equal(sr.Agents[0].node(), Node{
+		Role:    AgentPublicRole,
+		IP:      "10.0.0.3",
+		MesosID: "a1-S1",
+		Mesos: &mesosAgentInfo{
+			RegisteredTime: time.Unix(1497373938, 5e8).UTC(),
+			Active:         true,
+			Resources:      map[string]interface{}{"cpus": float64(4), "ports": "[1025-2180]"},
+			Attributes:     map[string]string{"public_ip": "true", "rack": "r1", "zone": "2"},
+		},
+	})
+}
+
+func (s *DiscoveryTestSuit) TestHistoryServiceAgents() {
+	historyDir := filepath.Join(s.dir, "minute")
+	s.assert.NoError(os.Mkdir(historyDir, 0755))
+	for name, agents := range map[string]string{
+		"2017-06-13T17:00:00.json": `{"slaves": [{"id": "a1-S1", "hostname": "10.0.0.2", "active": true}]}`,
+		"2017-06-13T17:00:30.json": `{"slaves": [{"id": "a1-S1", "hostname": "10.0.0.2", "active": false},
+			{"id": "a1-S2", "hostname": "10.0.0.3", "attributes": {"public_ip": "true"}}]}`,
+	} {
+		s.assert.NoError(ioutil.WriteFile(filepath.Join(historyDir, name), []byte(strconv.Quote(agents)), 0644))
+	}
+
+	nodes, err := (&findAgentsInHistoryService{basePath: s.dir, pastTime: "/minute/"}).find()
+	s.assert.NoError(err)
+	s.assert.Len(nodes, 2)
+	s.assert.Equal(nodes[0].MesosID, "a1-S1")
+	s.assert.Equal(nodes[0].Role, AgentRole)
+	s.assert.False(nodes[0].Mesos.Active)
+	s.assert.Equal(nodes[1].MesosID, "a1-S2")
+	s.assert.Equal(nodes[1].Role, AgentPublicRole)
+
+	// a diagnostics bundle could be requested by a mesos id before the nodes are pulled
+	matched, err := matchRequestedNodes([]string{"a1-S2"}, nil, nodes)
+	s.assert.NoError(err)
+	s.assert.Equal(matched, nodes[1:])
+}
+
+func (s *DiscoveryTestSuit) TestDiscoveryHandler() {
+	router := NewRouter(Dt{
+		Cfg: &testCfg,
+		DtDCOSTools: &DCOSTools{
+			DiscoveryChain: &DiscoveryChain{
+				Masters: []DiscoveryProvider{{Name: DiscoveryStatic}},
+				Agents:  []DiscoveryProvider{{Name: DiscoveryStatic}},
+			},
+			StaticNodes: []StaticNode{{IP: "10.0.0.1", Role: MasterRole}, {IP: "10.0.0.2", Role: AgentPublicRole}},
+		},
+	})
+	response, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/discovery", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+
+	var traces map[string]discoveryTrace
+	s.assert.NoError(json.Unmarshal(response, &traces))
+	s.assert.Equal(traces[MasterRole].Steps[0].Nodes, []string{"10.0.0.1"})
+	s.assert.Equal(traces[AgentRole].Steps[0].Nodes, []string{"10.0.0.2"})
+}
+
+func (s *DiscoveryTestSuit) TestPullNodePort() {
+	st := &fakeDCOSTools{
+		fakeMasters: []Node{{IP: "10.0.0.1", Role: MasterRole, Port: 61001}},
+	}
+	runPull(Dt{Cfg: &testCfg, DtDCOSTools: st})
+	s.assert.Contains(st.getRequestsMade, "http://10.0.0.1:61001/system/health/v1")
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{})
+}
+
+func (s *DiscoveryTestSuit) TestSRVDiscovery() {
+	finder := &findNodesInSRV{
+		record: "_3dt._tcp.agents.example.com",
+		role:   AgentRole,
+		scheme: "https",
+		lookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			s.assert.Equal(name, "_3dt._tcp.agents.example.com")
+			return "", []*net.SRV{
+				{Target: "agent-1.example.com.", Port: 61001},
+				{Target: "10.0.0.3", Port: 61002},
+				{Target: "unknown.example.com.", Port: 61003},
+			}, nil
+		},
+		lookupHost: func(ctx context.Context, host string) ([]string, error) {
+			if host == "agent-1.example.com" {
+				return []string{"10.0.0.2"}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+	nodes, err := finder.find()
+	s.assert.NoError(err)
+	s.assert.Equal(nodes, []Node{
+		{Role: AgentRole, IP: "10.0.0.2", Host: "agent-1.example.com", Port: 61001, Scheme: "https"},
+		{Role: AgentRole, IP: "10.0.0.3", Host: "10.0.0.3", Port: 61002, Scheme: "https"},
+	})
+
+	_, err = (&DCOSTools{}).findWithProvider(MasterRole, DiscoveryProvider{Name: DiscoverySRV})
+	s.assert.Error(err)
+}
+
+func (s *DiscoveryTestSuit) TestNodeBaseURL() {
+	cfg := testCfg
+	for _, test := range []struct {
+		node     Node
+		forceTLS bool
+		expected string
+	}{
+		{node: Node{IP: "10.0.0.1", Role: MasterRole}, expected: "http://10.0.0.1:1050"},
+		{node: Node{IP: "10.0.0.1", Role: MasterRole}, forceTLS: true, expected: "https://10.0.0.1:1050"},
+		{node: Node{IP: "10.0.0.2", Role: AgentRole, Port: 61001, Scheme: "https"}, expected: "https://10.0.0.2:61001"},
+		{node: Node{IP: "10.0.0.2", Role: AgentRole, Scheme: "http"}, forceTLS: true, expected: "https://10.0.0.2:1050"},
+	} {
+		cfg.FlagForceTLS = test.forceTLS
+		url, err := nodeBaseURL(&cfg, test.node)
+		s.assert.NoError(err)
+		s.assert.Equal(url, test.expected)
+	}
+
+	// the port listed by a node is replaced with the discovered port
+	cfg.FlagForceTLS = false
+	url, err := logEndpointURL(&cfg, Node{IP: "10.0.0.2", Port: 61001}, ":1050/system/health/v1/logs/units/dcos-mesos-slave.service")
+	s.assert.NoError(err)
+	s.assert.Equal(url, "http://10.0.0.2:61001/system/health/v1/logs/units/dcos-mesos-slave.service")
+}
+
+func (s *DiscoveryTestSuit) TestRemoteBundleCallsUseNodePort() {
+	st := &fakeDCOSTools{
+		fakeMasters: []Node{{IP: "10.0.0.1", Role: MasterRole, Port: 61001, Scheme: "https"}},
+	}
+	st.makeMockedResponse("https://10.0.0.1:61001/system/health/v1/report/diagnostics/list",
+		[]byte(`[{"file_name": "/system/health/v1/report/diagnostics/serve/bundle-1.zip", "file_size": 123}]`),
+		http.StatusOK, nil)
+
+	bundles, err := listAllBundles(&testCfg, st)
+	s.assert.NoError(err)
+	s.assert.Contains(bundles, "10.0.0.1:61001")
+
+	job := &DiagnosticsJob{}
+	node, location, ok, err := job.isBundleAvailable("bundle-1.zip", &testCfg, st)
+	s.assert.NoError(err)
+	s.assert.True(ok)
+	s.assert.Equal(node.Port, 61001)
+	s.assert.Equal(location, "/system/health/v1/report/diagnostics/serve/bundle-1.zip")
+	s.assert.Equal(findMasterNode("10.0.0.1", st).Scheme, "https")
+}
+
+func TestDiscoveryTestSuit(t *testing.T) {
+	suite.Run(t, new(DiscoveryTestSuit))
+}
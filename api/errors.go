@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/dcos/3dt/client"
+)
+
+// requestIDHeader is a header with a request ID. A request ID sent by a client is used, otherwise a new one is
+// generated. The request ID is returned in the response header and in the error responses.
+const requestIDHeader = "X-Request-ID"
+
+// Error codes returned in the error responses.
+const (
+	errorCodeNotFound        = "not_found"
+	errorCodeInvalidArgument = "invalid_argument"
+	errorCodeForbidden       = "forbidden"
+	errorCodeConflict        = "conflict"
+	errorCodeUnavailable     = "unavailable"
+	errorCodeTimeout         = "timeout"
+	errorCodeInternal        = "internal"
+)
+
+var errorCodeStatus = map[string]int{
+	errorCodeNotFound:        http.StatusNotFound,
+	errorCodeInvalidArgument: http.StatusBadRequest,
+	errorCodeForbidden:       http.StatusForbidden,
+	errorCodeConflict:        http.StatusConflict,
+	errorCodeUnavailable:     http.StatusServiceUnavailable,
+	errorCodeTimeout:         http.StatusGatewayTimeout,
+	errorCodeInternal:        http.StatusInternalServerError,
+}
+
+// apiError is an error with an error code, the code defines the HTTP status code of the response.
+type apiError struct {
+	code string
+	msg  string
+}
+
+func (e apiError) Error() string {
+	return e.msg
+}
+
+// notFoundError is returned if a requested unit, node, cluster or bundle does not exist.
+func notFoundError(format string, a ...interface{}) error {
+	return apiError{code: errorCodeNotFound, msg: fmt.Sprintf(format, a...)}
+}
+
+// invalidArgumentError is returned if a request is malformed.
+func invalidArgumentError(format string, a ...interface{}) error {
+	return apiError{code: errorCodeInvalidArgument, msg: fmt.Sprintf(format, a...)}
+}
+
+// forbiddenError is returned if a client is not allowed to make a request, e.g. to push a report of another node.
+func forbiddenError(format string, a ...interface{}) error {
+	return apiError{code: errorCodeForbidden, msg: fmt.Sprintf(format, a...)}
+}
+
+// conflictError is returned if a request conflicts with the current state, e.g. a diagnostics job is already running.
+func conflictError(format string, a ...interface{}) error {
+	return apiError{code: errorCodeConflict, msg: fmt.Sprintf(format, a...)}
+}
+
+// unavailableError is returned if a request could not be served at the moment, e.g. the health report is not
+// pulled yet.
+func unavailableError(format string, a ...interface{}) error {
+	return apiError{code: errorCodeUnavailable, msg: fmt.Sprintf(format, a...)}
+}
+
+// errorCode returns an error code of an error, errors without a code are internal errors.
+func errorCode(err error) string {
+	if e, ok := err.(apiError); ok {
+		return e.code
+	}
+	return errorCodeInternal
+}
+
+// errorStatusCode returns an HTTP status code for an error.
+func errorStatusCode(err error) int {
+	return errorCodeStatus[errorCode(err)]
+}
+
+// errorStatusCodeOr returns an HTTP status code for an error with an error code, the default status code otherwise.
+func errorStatusCodeOr(err error, status int) int {
+	if _, ok := err.(apiError); ok {
+		return errorStatusCode(err)
+	}
+	return status
+}
+
+// statusErrorCode returns an error code for an HTTP status code.
+func statusErrorCode(status int) string {
+	for code, s := range errorCodeStatus {
+		if s == status {
+			return code
+		}
+	}
+	return errorCodeInternal
+}
+
+// errorResponse is a JSON body of an error response.
+type errorResponse = client.ErrorResponse
+
+// writeError responds with an error, the status code is defined by the error code.
+func writeError(w http.ResponseWriter, err error) {
+	httpError(w, err.Error(), errorStatusCode(err))
+}
+
+// writeErrorOr responds with an error, the status code is defined by the error code or is the default status code
+// for the errors without a code.
+func writeErrorOr(w http.ResponseWriter, err error, status int) {
+	httpError(w, err.Error(), errorStatusCodeOr(err, status))
+}
+
+// httpError responds with an error message and an HTTP status code.
+func httpError(w http.ResponseWriter, msg string, code int) {
+	requestID := w.Header().Get(requestIDHeader)
+	log.WithField("request_id", requestID).Error(msg)
+
+	// an error response is not a representation of the health report snapshot.
+	w.Header().Del("ETag")
+	w.Header().Del("Last-Modified")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	response := errorResponse{
+		Code:      statusErrorCode(code),
+		Message:   msg,
+		RequestID: requestID,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("Could not generate a request ID: %s", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware sets a request ID header in a request and a response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+			r.Header.Set(requestIDHeader, requestID)
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dcos/3dt/client"
+)
+
+// FederationBaseRoute a base federation endpoint location.
+const FederationBaseRoute = "/federation/v1"
+
+// FederatedClusterConfig is a DC/OS cluster entry in a federation config file. Each cluster has its own
+// CA certificate and HTTP headers, e.g. an authorization token.
+type FederatedClusterConfig struct {
+	Name       string            `json:"name"`
+	URL        string            `json:"url"`
+	CACertFile string            `json:"ca-cert,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+type federationConfig struct {
+	Clusters []FederatedClusterConfig `json:"clusters"`
+}
+
+// Federation periodically fetches health reports from multiple DC/OS clusters.
+type Federation struct {
+	sync.RWMutex
+	clusters map[string]*federatedCluster
+}
+
+type federatedCluster struct {
+	config    FederatedClusterConfig
+	requester client.Requester
+
+	// report is replaced with a new instance on every successful fetch.
+	report    *monitoringResponse
+	fetched   time.Time
+	lastError string
+}
+
+// federated clusters response
+type federationClustersResponseJSONStruct struct {
+	Array []federatedClusterResponseFieldsStruct `json:"clusters"`
+}
+
+type federatedClusterResponseFieldsStruct struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Health      int       `json:"health"`
+	UpdatedTime time.Time `json:"updated_time"`
+	FetchedTime time.Time `json:"fetched_time"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// federated units response
+type federationUnitsResponseJSONStruct struct {
+	Array []federatedUnitResponseFieldsStruct `json:"units"`
+}
+
+type federatedUnitResponseFieldsStruct struct {
+	Cluster string `json:"cluster"`
+	unitResponseFieldsStruct
+}
+
+// Init loads a federation config file and prepares an HTTP requester for each cluster.
+func (f *Federation) Init(config *Config) error {
+	content, err := ioutil.ReadFile(config.FlagFederationConfigFile)
+	if err != nil {
+		return err
+	}
+
+	var fc federationConfig
+	if err := json.Unmarshal(content, &fc); err != nil {
+		return err
+	}
+	if len(fc.Clusters) == 0 {
+		return fmt.Errorf("no clusters found in %s", config.FlagFederationConfigFile)
+	}
+
+	clusters := make(map[string]*federatedCluster)
+	for _, c := range fc.Clusters {
+		if c.Name == "" || c.URL == "" {
+			return errors.New("cluster name and url must be set")
+		}
+		if _, ok := clusters[c.Name]; ok {
+			return fmt.Errorf("cluster %s is defined more than once", c.Name)
+		}
+
+		// every cluster uses its own CA and headers.
+		requester, err := client.NewRequester(client.Options{CACertFile: c.CACertFile, Headers: c.Headers})
+		if err != nil {
+			return fmt.Errorf("could not init HTTP requester for cluster %s: %s", c.Name, err)
+		}
+
+		clusters[c.Name] = &federatedCluster{
+			config:    c,
+			requester: requester,
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.clusters = clusters
+	return nil
+}
+
+// StartFederationWithInterval will start to fetch the federated clusters health reports.
+func StartFederationWithInterval(dt Dt) {
+	for {
+		dt.DtFederation.fetch(time.Duration(dt.Cfg.FlagPullTimeoutSec) * time.Second)
+		time.Sleep(time.Duration(dt.Cfg.FlagPullInterval) * time.Second)
+	}
+}
+
+// fetch gets health reports from all clusters concurrently. A cluster keeps its last report if the fetch fails.
+func (f *Federation) fetch(timeout time.Duration) {
+	f.RLock()
+	clusters := make(map[string]*federatedCluster)
+	for name, c := range f.clusters {
+		clusters[name] = c
+	}
+	f.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, c := range clusters {
+		wg.Add(1)
+		go func(name string, c *federatedCluster) {
+			defer wg.Done()
+			report, err := fetchClusterReport(c.config.URL, c.requester, timeout)
+
+			f.Lock()
+			defer f.Unlock()
+			c.fetched = time.Now()
+			if err != nil {
+				logrus.Errorf("Could not fetch a health report from cluster %s: %s", name, err)
+				c.lastError = err.Error()
+				return
+			}
+			c.report = report
+			c.lastError = ""
+		}(name, c)
+	}
+	wg.Wait()
+}
+
+func fetchClusterReport(clusterURL string, requester client.Requester, timeout time.Duration) (*monitoringResponse, error) {
+	report, err := client.New(clusterURL, requester, timeout).Report()
+	if err != nil {
+		return nil, err
+	}
+	return &monitoringResponse{
+		Units:       report.Units,
+		Nodes:       report.Nodes,
+		UpdatedTime: report.UpdatedTime,
+	}, nil
+}
+
+// getClusterReport returns the last health report fetched from a cluster.
+func (f *Federation) getClusterReport(name string) (*monitoringResponse, error) {
+	f.RLock()
+	defer f.RUnlock()
+	c, ok := f.clusters[name]
+	if !ok {
+		return nil, notFoundError("Cluster %s not found", name)
+	}
+	if c.report == nil {
+		return nil, unavailableError("Health report was not fetched from cluster %s yet: %s", name, c.lastError)
+	}
+	return c.report, nil
+}
+
+// clusterNames returns sorted cluster names.
+func (f *Federation) clusterNames() []string {
+	f.RLock()
+	defer f.RUnlock()
+	var names []string
+	for name := range f.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetClusters returns the federated clusters and their health. A cluster health is the worst unit health,
+// a cluster without a health report is reported with health 3.
+func (f *Federation) GetClusters() federationClustersResponseJSONStruct {
+	var response federationClustersResponseJSONStruct
+	for _, name := range f.clusterNames() {
+		f.RLock()
+		c := f.clusters[name]
+		cluster := federatedClusterResponseFieldsStruct{
+			Name:        name,
+			URL:         c.config.URL,
+			Health:      3,
+			FetchedTime: c.fetched,
+			Error:       c.lastError,
+		}
+		report := c.report
+		f.RUnlock()
+
+		if report != nil {
+			report.RLock()
+			cluster.Health = 0
+			cluster.UpdatedTime = report.UpdatedTime
+			for _, u := range report.Units {
+				if u.Health > cluster.Health {
+					cluster.Health = u.Health
+				}
+			}
+			report.RUnlock()
+		}
+		response.Array = append(response.Array, cluster)
+	}
+	return response
+}
+
+// GetAllUnits returns units from all federated clusters.
+func (f *Federation) GetAllUnits() federationUnitsResponseJSONStruct {
+	var response federationUnitsResponseJSONStruct
+	for _, name := range f.clusterNames() {
+		report, err := f.getClusterReport(name)
+		if err != nil {
+			logrus.Debug(err)
+			continue
+		}
+		for _, u := range report.GetAllUnits().Array {
+			response.Array = append(response.Array, federatedUnitResponseFieldsStruct{
+				Cluster:                  name,
+				unitResponseFieldsStruct: u,
+			})
+		}
+	}
+	return response
+}
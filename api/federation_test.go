@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FederationTestSuit struct {
+	suite.Suite
+	assert     *assertPackage.Assertions
+	dir        string
+	prod, test *httptest.Server
+}
+
+func (s *FederationTestSuit) SetupTest() {
+	s.assert = assertPackage.New(s.T())
+
+	var err error
+	s.dir, err = ioutil.TempDir("", "3dt-federation")
+	s.assert.NoError(err)
+
+	s.prod = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token=prod" || r.URL.Path != "/system/health/v1/report" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{
+			"Units": {
+				"dcos-marathon.service": {"UnitName": "dcos-marathon.service", "Health": 1, "PrettyName": "Marathon",
+					"Nodes": [{"IP": "10.0.7.1", "Role": "master", "Health": 1}]}
+			},
+			"Nodes": {"10.0.7.1": {"IP": "10.0.7.1", "Role": "master", "Health": 1}},
+			"UpdatedTime": "%s"
+		}`, time.Now().Format(time.RFC3339Nano))
+	}))
+	s.test = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+}
+
+func (s *FederationTestSuit) TearDownTest() {
+	s.prod.Close()
+	s.test.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *FederationTestSuit) makeFederation(clusters string) (*Federation, error) {
+	cfg := testCfg
+	cfg.FlagFederationConfigFile = filepath.Join(s.dir, "federation.json")
+	s.assert.NoError(ioutil.WriteFile(cfg.FlagFederationConfigFile, []byte(clusters), 0644))
+
+	federation := &Federation{}
+	return federation, federation.Init(&cfg)
+}
+
+func (s *FederationTestSuit) TestFederationInitFails() {
+	for _, clusters := range []string{
+		`{"clusters": []}`,
+		`{"clusters": [{"name": "prod"}]}`,
+		`{"clusters": [{"name": "prod", "url": "http://a"}, {"name": "prod", "url": "http://b"}]}`,
+		`{"clusters": [{"name": "prod", "url": "http://a", "ca-cert": "/not/found.crt"}]}`,
+	} {
+		_, err := s.makeFederation(clusters)
+		s.assert.Error(err, clusters)
+	}
+}
+
+func (s *FederationTestSuit) TestFederation() {
+	federation, err := s.makeFederation(fmt.Sprintf(`{"clusters": [
+		{"name": "prod", "url": "%s/", "headers": {"Authorization": "token=prod"}},
+		{"name": "test", "url": "%s"}
+	]}`, s.prod.URL, s.test.URL))
+	s.assert.NoError(err)
+	federation.fetch(time.Second)
+
+	clusters := federation.GetClusters()
+	s.assert.Len(clusters.Array, 2)
+	s.assert.Equal(clusters.Array[0].Name, "prod")
+	s.assert.Equal(clusters.Array[0].Health, 1)
+	s.assert.Empty(clusters.Array[0].Error)
+	s.assert.Equal(clusters.Array[1].Name, "test")
+	s.assert.Equal(clusters.Array[1].Health, 3)
+	s.assert.Contains(clusters.Array[1].Error, "status code 503")
+
+	units := federation.GetAllUnits()
+	s.assert.Equal(units.Array, []federatedUnitResponseFieldsStruct{
+		{
+			Cluster: "prod",
+			unitResponseFieldsStruct: unitResponseFieldsStruct{
+				UnitID:     "dcos-marathon.service",
+				PrettyName: "Marathon",
+				UnitHealth: 1,
+			},
+		},
+	})
+
+	router := NewRouter(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}, DtFederation: federation})
+	response, code, err := MakeHTTPRequest(s.T(), router, "/federation/v1/clusters/prod/units/dcos-marathon.service/nodes", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+
+	var nodes nodesResponseJSONStruct
+	s.assert.NoError(json.Unmarshal(response, &nodes))
+	s.assert.Equal(nodes.Array, []*nodeResponseFieldsStruct{{HostIP: "10.0.7.1", NodeHealth: 1, NodeRole: MasterRole}})
+
+	for url, expectedCode := range map[string]int{
+		"/federation/v1/clusters":                                 http.StatusOK,
+		"/federation/v1/units":                                    http.StatusOK,
+		"/federation/v1/clusters/prod/report":                     http.StatusOK,
+		"/federation/v1/clusters/prod/nodes/10.0.7.1/units":       http.StatusOK,
+		"/federation/v1/clusters/prod/units/dcos-unknown.service": http.StatusNotFound,
+		"/federation/v1/clusters/test/units":                      http.StatusServiceUnavailable,
+		"/federation/v1/clusters/unknown/units":                   http.StatusNotFound,
+	} {
+		_, code, err := MakeHTTPRequest(s.T(), router, url, "GET", nil)
+		s.assert.NoError(err)
+		s.assert.Equal(code, expectedCode, url)
+	}
+
+	// the last report is kept if a cluster is not available
+	s.prod.Close()
+	federation.fetch(time.Second)
+	clusters = federation.GetClusters()
+	s.assert.Equal(clusters.Array[0].Health, 1)
+	s.assert.NotEmpty(clusters.Array[0].Error)
+}
+
+func (s *FederationTestSuit) TestFederationRoutesDisabled() {
+	router := NewRouter(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}})
+	_, code, err := MakeHTTPRequest(s.T(), router, "/federation/v1/clusters", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusNotFound)
+}
+
+func TestFederationTestSuit(t *testing.T) {
+	suite.Run(t, new(FederationTestSuit))
+}
@@ -6,22 +6,20 @@ import (
 	"io"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 )
 
-func httpError(w http.ResponseWriter, msg string, code int) {
-	log.Error(msg)
-	http.Error(w, msg, code)
-}
-
 // Route handlers
 // /api/v1/system/health, get a units status, used by 3dt puller
 func unitsHealthStatus(w http.ResponseWriter, r *http.Request, dt Dt) {
-	health, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools)
+	health, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools, dt.DtKnowledgeBase)
 	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -32,9 +30,56 @@ func unitsHealthStatus(w http.ResponseWriter, r *http.Request, dt Dt) {
 	}
 }
 
+// /api/v1/system/health/metrics, get the local units health and system metrics in the Prometheus format. Masters
+// pulling the cluster also expose the cluster health.
+func metricsHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	health, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools, dt.DtKnowledgeBase)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	families := localMetrics(health)
+	if dt.Cfg.FlagPull {
+		families = append(families, globalMonitoringResponse.clusterMetrics(time.Now())...)
+	}
+	if err := writeMetrics(w, families); err != nil {
+		log.Errorf("Failed to write metrics: %s", err)
+	}
+}
+
+// /api/v1/system/health/summary, get the cluster health summary. Responds with 503 if the summary policy fails,
+// so the endpoint could be used by load balancer health checks.
+func getSummaryHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	summary, ok := globalMonitoringResponse.GetSummary(summaryPolicy(dt.Cfg),
+		time.Duration(dt.Cfg.FlagSummaryMaxAgeSec)*time.Second, time.Now())
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
 // /api/v1/system/health/units, get an array of all units collected from all hosts in a cluster
 func getAllUnitsHandler(w http.ResponseWriter, r *http.Request) {
-	if err := json.NewEncoder(w).Encode(globalMonitoringResponse.GetAllUnits()); err != nil {
+	query, err := parseListQuery(r.URL.RawQuery, unitQueryFields, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	units := globalMonitoringResponse.GetAllUnits()
+	units.Array, units.Total = query.applyToUnits(units.Array)
+	if format != formatJSON {
+		writeView(w, globalMonitoringResponse.newUnitsView(units.Array), format)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(units); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
 	}
 }
@@ -44,7 +89,7 @@ func getUnitByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	unitResponse, err := globalMonitoringResponse.GetUnit(vars["unitid"])
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if err := json.NewEncoder(w).Encode(unitResponse); err != nil {
@@ -55,11 +100,17 @@ func getUnitByIDHandler(w http.ResponseWriter, r *http.Request) {
 // /api/v1/system/health/units/:unit_id:/nodes
 func getNodesByUnitIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	query, err := parseListQuery(r.URL.RawQuery, nodeQueryFields, "host_ip")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	nodesForUnitResponse, err := globalMonitoringResponse.GetNodesForUnit(vars["unitid"])
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	nodesForUnitResponse.Array, nodesForUnitResponse.Total = query.applyToNodes(nodesForUnitResponse.Array)
 	if err := json.NewEncoder(w).Encode(nodesForUnitResponse); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
 	}
@@ -71,7 +122,7 @@ func getNodeByUnitIDNodeIDHandler(w http.ResponseWriter, r *http.Request) {
 	nodePerUnit, err := globalMonitoringResponse.GetSpecificNodeForUnit(vars["unitid"], vars["nodeid"])
 
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if err := json.NewEncoder(w).Encode(nodePerUnit); err != nil {
@@ -79,8 +130,38 @@ func getNodeByUnitIDNodeIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// /api/v1/system/health/versions, get nodes grouped by DC/OS and 3dt versions
+func getVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(globalMonitoringResponse.GetVersions()); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /api/v1/system/health/discovery, look up cluster nodes and show how each discovery provider responded
+func discoveryHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if _, err := dt.DtDCOSTools.GetMasterNodes(); err != nil {
+		log.Errorf("Could not find master nodes: %s", err)
+	}
+	if _, err := dt.DtDCOSTools.GetAgentNodes(); err != nil {
+		log.Errorf("Could not find agent nodes: %s", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(globalDiscoveryTraces.get()); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
 // list the entire tree
 func reportHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if format != formatJSON {
+		writeView(w, globalMonitoringResponse.newReportView(), format)
+		return
+	}
 	if err := json.NewEncoder(w).Encode(globalMonitoringResponse); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
 	}
@@ -88,7 +169,23 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 
 // /api/v1/system/health/nodes
 func getNodesHandler(w http.ResponseWriter, r *http.Request) {
-	if err := json.NewEncoder(w).Encode(globalMonitoringResponse.GetNodes()); err != nil {
+	query, err := parseListQuery(r.URL.RawQuery, nodeQueryFields, "host_ip")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	nodes := globalMonitoringResponse.GetNodes()
+	nodes.Array, nodes.Total = query.applyToNodes(nodes.Array)
+	if format != formatJSON {
+		writeView(w, globalMonitoringResponse.newNodesView(nodes.Array), format)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(nodes); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
 	}
 }
@@ -98,7 +195,7 @@ func getNodeByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	nodes, err := globalMonitoringResponse.GetNodeByID(vars["nodeid"])
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -110,22 +207,65 @@ func getNodeByIDHandler(w http.ResponseWriter, r *http.Request) {
 // /api/v1/system/health/nodes/:node_id:/units
 func getNodeUnitsByNodeIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	query, err := parseListQuery(r.URL.RawQuery, unitQueryFields, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	units, err := globalMonitoringResponse.GetNodeUnitsID(vars["nodeid"])
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	units.Array, units.Total = query.applyToUnits(units.Array)
 
 	if err := json.NewEncoder(w).Encode(units); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
 	}
 }
 
+// /api/v1/system/health/nodes/:node_id:/system
+func getNodeSystemHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	system, err := globalMonitoringResponse.GetNodeSystem(vars["nodeid"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(system); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /api/v1/system/health/system/top?limit=<limit>&role=<role>, get the nodes with the highest resource usage
+func getSystemTopHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			writeError(w, invalidArgumentError("limit must be a positive integer, got %s", l))
+			return
+		}
+	}
+
+	role := r.URL.Query().Get("role")
+	if role != "" && role != MasterRole && role != AgentRole && role != AgentPublicRole {
+		writeError(w, invalidArgumentError("Incorrect role %s, must be: %s, %s or %s", role, MasterRole, AgentRole,
+			AgentPublicRole))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(globalMonitoringResponse.GetSystemTop(limit, role)); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
 func getNodeUnitByNodeIDUnitIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	unit, err := globalMonitoringResponse.GetNodeUnitByNodeIDUnitID(vars["nodeid"], vars["unitid"])
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if err := json.NewEncoder(w).Encode(unit); err != nil {
@@ -133,8 +273,210 @@ func getNodeUnitByNodeIDUnitIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// availabilityWindow returns a time window from the request query, 30 days by default.
+func availabilityWindow(r *http.Request) (time.Duration, error) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		return 30 * 24 * time.Hour, nil
+	}
+	return parseWindow(window)
+}
+
+// /api/v1/system/health/units/:unit_id:/availability
+func getUnitAvailabilityHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if dt.DtHealthHistory == nil {
+		writeError(w, unavailableError("Health history is not available"))
+		return
+	}
+
+	window, err := availabilityWindow(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	availability, err := dt.DtHealthHistory.GetUnitAvailability(vars["unitid"], window)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(availability); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /api/v1/system/health/nodes/:node_id:/availability
+func getNodeAvailabilityHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if dt.DtHealthHistory == nil {
+		writeError(w, unavailableError("Health history is not available"))
+		return
+	}
+
+	window, err := availabilityWindow(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	availability, err := dt.DtHealthHistory.GetNodeAvailability(vars["nodeid"], window)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(availability); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /api/v1/system/health/availability, get availability of all units and nodes
+func getAvailabilityHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if dt.DtHealthHistory == nil {
+		writeError(w, unavailableError("Health history is not available"))
+		return
+	}
+
+	window, err := availabilityWindow(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(dt.DtHealthHistory.GetAvailability(window)); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /api/v1/system/health/known-nodes, get all nodes seen in the cluster
+func getKnownNodesHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if dt.DtNodeTracker == nil {
+		writeError(w, unavailableError("Node tracker is not available"))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(dt.DtNodeTracker.GetKnownNodes()); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// DELETE /api/v1/system/health/nodes/<nodeid>, forget a missing node
+func forgetNodeHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if dt.DtNodeTracker == nil {
+		writeError(w, unavailableError("Node tracker is not available"))
+		return
+	}
+
+	nodeID := mux.Vars(r)["nodeid"]
+	known, ok := dt.DtNodeTracker.getKnownNode(nodeID)
+	if !ok {
+		writeError(w, notFoundError("Node %s not found", nodeID))
+		return
+	}
+	if !known.Missing {
+		writeError(w, conflictError("Node %s is not missing, only missing nodes can be removed", nodeID))
+		return
+	}
+
+	if err := dt.DtNodeTracker.Forget(nodeID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(known); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /federation/v1/clusters, get federated clusters health
+func getFederatedClustersHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if err := json.NewEncoder(w).Encode(dt.DtFederation.GetClusters()); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// /federation/v1/units, get units from all federated clusters
+func getFederatedUnitsHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	if err := json.NewEncoder(w).Encode(dt.DtFederation.GetAllUnits()); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// federatedClusterHandler returns a handler function which responds with the data from a federated cluster report.
+func federatedClusterHandler(dt Dt, get func(report *monitoringResponse, vars map[string]string) (interface{}, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		report, err := dt.DtFederation.getClusterReport(vars["cluster"])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response, err := get(report, vars)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Failed to encode responses to json: %s", err)
+		}
+	}
+}
+
+// healthV2Handler returns a handler function which responds with a v2 API resource.
+func healthV2Handler(get func(vars map[string]string) (interface{}, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := get(mux.Vars(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Failed to encode responses to json: %s", err)
+		}
+	}
+}
+
+// A handler function accepts a health report pushed by a node. Pushed reports are merged into the cluster health
+// on the next pull. Masters which do not pull the cluster nodes merge the pushed reports immediately.
+func pushHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	var report UnitsHealthResponseJSONStruct
+	r.Body = http.MaxBytesReader(w, r.Body, maxPushedReportSize)
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		response, _ := prepareResponseWithErr(http.StatusBadRequest, err)
+		writeResponse(w, response)
+		return
+	}
+
+	node, err := pushingNode(r, report, dt.Cfg.FlagPushToken, dt.DtDCOSTools)
+	if err != nil {
+		response, _ := prepareResponseWithErr(errorStatusCode(err), err)
+		writeResponse(w, response)
+		return
+	}
+
+	// a node reports its own health only, the role is the role found by the discovery.
+	report.IPAddress = node.IP
+	report.Role = node.Role
+	globalPushedReports.add(report)
+	if !dt.Cfg.FlagPull {
+		publishPushedReports(dt)
+	}
+
+	response, _ := prepareResponseOk(http.StatusOK, "Health report accepted from "+report.IPAddress)
+	writeResponse(w, response)
+}
+
+// setResponseError sets the error response fields of a failed diagnostics response.
+func setResponseError(w http.ResponseWriter, response *diagnosticsReportResponse) {
+	if response.ResponseCode < http.StatusBadRequest {
+		return
+	}
+	response.Code = statusErrorCode(response.ResponseCode)
+	response.Message = response.Status
+	response.RequestID = w.Header().Get(requestIDHeader)
+}
+
 // A helper function to send a response.
 func writeResponse(w http.ResponseWriter, response diagnosticsReportResponse) {
+	setResponseError(w, &response)
 	w.WriteHeader(response.ResponseCode)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
@@ -142,6 +484,7 @@ func writeResponse(w http.ResponseWriter, response diagnosticsReportResponse) {
 }
 
 func writeCreateResponse(w http.ResponseWriter, response createResponse) {
+	setResponseError(w, &response.DiagnosticsResponse)
 	w.WriteHeader(response.ResponseCode)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Errorf("Failed to encode responses to json: %s", err)
@@ -172,7 +515,7 @@ func diagnosticsJobStatusHandler(w http.ResponseWriter, r *http.Request, dt Dt)
 func diagnosticsJobStatusAllHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 	status, err := dt.DtDiagnosticsJob.getStatusAll(dt.Cfg, dt.DtDCOSTools)
 	if err != nil {
-		response, _ := prepareResponseWithErr(http.StatusServiceUnavailable, err)
+		response, _ := prepareResponseWithErr(errorStatusCodeOr(err, http.StatusServiceUnavailable), err)
 		writeResponse(w, response)
 		return
 	}
@@ -195,7 +538,7 @@ func cancelBundleReportHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 func listAvailableGLobalBundlesFilesHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 	allBundles, err := listAllBundles(dt.Cfg, dt.DtDCOSTools)
 	if err != nil {
-		response, _ := prepareResponseWithErr(http.StatusServiceUnavailable, err)
+		response, _ := prepareResponseWithErr(errorStatusCodeOr(err, http.StatusServiceUnavailable), err)
 		writeResponse(w, response)
 		return
 	}
@@ -208,7 +551,7 @@ func listAvailableGLobalBundlesFilesHandler(w http.ResponseWriter, r *http.Reque
 func listAvailableLocalBundlesFilesHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 	matches, err := dt.DtDiagnosticsJob.findLocalBundle(dt.Cfg)
 	if err != nil {
-		response, _ := prepareResponseWithErr(http.StatusServiceUnavailable, err)
+		response, _ := prepareResponseWithErr(errorStatusCodeOr(err, http.StatusServiceUnavailable), err)
 		writeResponse(w, response)
 		return
 	}
@@ -238,7 +581,7 @@ func downloadBundleHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 	vars := mux.Vars(r)
 	node, location, ok, err := dt.DtDiagnosticsJob.isBundleAvailable(vars["file"], dt.Cfg, dt.DtDCOSTools)
 	if err != nil {
-		http.NotFound(w, r)
+		writeErrorOr(w, err, http.StatusServiceUnavailable)
 		return
 	}
 	if ok {
@@ -252,22 +595,28 @@ func downloadBundleHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 		}
 
 		// proxy to appropriate host with a file.
-		scheme := "http"
-		if dt.Cfg.FlagForceTLS {
-			scheme = "https"
+		baseURL, err := nodeBaseURL(dt.Cfg, node)
+		if err != nil {
+			writeErrorOr(w, err, http.StatusServiceUnavailable)
+			return
+		}
+		target, err := url.Parse(baseURL)
+		if err != nil {
+			writeErrorOr(w, err, http.StatusServiceUnavailable)
+			return
 		}
 
 		director := func(req *http.Request) {
 			req = r
-			req.URL.Scheme = scheme
-			req.URL.Host = fmt.Sprintf("%s:%d", node, dt.Cfg.FlagPort)
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
 			req.URL.Path = location
 		}
 		proxy := &httputil.ReverseProxy{Director: director}
 		proxy.ServeHTTP(w, r)
 		return
 	}
-	http.NotFound(w, r)
+	writeError(w, notFoundError("Bundle not found %s", vars["file"]))
 }
 
 // A handler function to start a diagnostics job.
@@ -304,8 +653,7 @@ func getUnitLogHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
 	vars := mux.Vars(r)
 	unitLogOut, err := dt.DtDiagnosticsJob.dispatchLogs(vars["provider"], vars["entity"], dt.Cfg, dt.DtDCOSTools)
 	if err != nil {
-		response, _ := prepareResponseWithErr(http.StatusServiceUnavailable, err)
-		writeResponse(w, response)
+		writeError(w, err)
 		return
 	}
 	log.Infof("Start read %s", vars["entity"])
@@ -2,6 +2,9 @@ package api
 
 import (
 	// intentionally rename package to do some magic
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -12,6 +15,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -29,12 +36,15 @@ type fakeDCOSTools struct {
 	units             []string
 	fakeHTTPResponses []*httpResponse
 	fakeMasters       []Node
+	fakeAgents        []Node
 
 	// HTTP GET, POST
 	mockedRequest    map[string]FakeHTTPContainer
 	getRequestsMade  []string
 	postRequestsMade []string
 	rawRequestsMade  []*http.Request
+
+	nodesCacheInvalidated bool
 }
 
 type FakeHTTPContainer struct {
@@ -136,6 +146,14 @@ func (st *fakeDCOSTools) Get(url string, timeout time.Duration) (body []byte, st
 			      "name":"PrettyName"
 			    }
 			  ],
+			  "system": {
+			    "memory": {"usedPercent": 40},
+			    "load_avarage": {"load1": 0.5},
+			    "disk_usage": [
+			      {"path": "/", "usedPercent": 20},
+			      {"path": "/var/lib", "usedPercent": 70}
+			    ]
+			  },
 			  "hostname":"master01",
 			  "ip":"127.0.0.1",
 			  "dcos_version":"1.6",
@@ -167,6 +185,13 @@ func (st *fakeDCOSTools) Get(url string, timeout time.Duration) (body []byte, st
 			      "name":"PrettyName"
 			    }
 			  ],
+			  "system": {
+			    "memory": {"usedPercent": 80},
+			    "load_avarage": {"load1": 2.5},
+			    "disk_usage": [
+			      {"path": "/", "usedPercent": 30}
+			    ]
+			  },
 			  "hostname":"agent01",
 			  "ip":"127.0.0.2",
 			  "dcos_version":"1.6",
@@ -214,6 +239,9 @@ func (st *fakeDCOSTools) GetMasterNodes() (nodes []Node, err error) {
 }
 
 func (st *fakeDCOSTools) GetAgentNodes() (nodes []Node, err error) {
+	if len(st.fakeAgents) > 0 {
+		return st.fakeAgents, nil
+	}
 	var fakeAgentHost Node
 	fakeAgentHost.IP = "127.0.0.2"
 	fakeAgentHost.Role = "agent"
@@ -221,6 +249,12 @@ func (st *fakeDCOSTools) GetAgentNodes() (nodes []Node, err error) {
 	return nodes, nil
 }
 
+func (st *fakeDCOSTools) InvalidateNodesCache() {
+	st.Lock()
+	defer st.Unlock()
+	st.nodesCacheInvalidated = true
+}
+
 type HandlersTestSuit struct {
 	suite.Suite
 	assert                              *assertPackage.Assertions
@@ -383,6 +417,7 @@ func (s *HandlersTestSuit) SetupTest() {
 func (s *HandlersTestSuit) TearDownTest() {
 	// clear global variables that might be set
 	globalMonitoringResponse = monitoringResponse{}
+	globalPushedReports.get(0)
 }
 
 // Helper functions
@@ -399,6 +434,19 @@ func (s *HandlersTestSuit) get(url string) []byte {
 	return response
 }
 
+// assertError checks an error response of a GET request.
+func (s *HandlersTestSuit) assertError(url string, code int, msg string) {
+	resp, statusCode, err := MakeHTTPRequest(s.T(), s.router, url, "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, statusCode, url)
+
+	var response errorResponse
+	s.assert.NoError(json.Unmarshal(resp, &response))
+	s.assert.Equal(statusErrorCode(code), response.Code)
+	s.assert.Equal(msg, response.Message)
+	s.assert.NotEmpty(response.RequestID)
+}
+
 func (s *HandlersTestSuit) TestgetAllUnitsHandlerFunc() {
 	// Test endpoint /system/health/v1/units
 	resp := s.get("/system/health/v1/units")
@@ -439,8 +487,7 @@ func (s *HandlersTestSuit) TestgetUnitByIdHandlerFunc() {
 	s.assert.Equal(response, expectedResponse, "Response is in incorrect format")
 
 	// Unit should not be found
-	resp = s.get("/system/health/v1/units/dcos-notfound.service")
-	s.assert.Equal(string(resp), "Unit dcos-notfound.service not found\n")
+	s.assertError("/system/health/v1/units/dcos-notfound.service", http.StatusNotFound, "Unit dcos-notfound.service not found")
 }
 
 func (s *HandlersTestSuit) TestgetNodesByUnitIdHandlerFunc() {
@@ -464,8 +511,7 @@ func (s *HandlersTestSuit) TestgetNodesByUnitIdHandlerFunc() {
 	})
 
 	// Unit should not be found and no nodes should be returned
-	resp = s.get("/system/health/v1/units/dcos-notfound.service/nodes")
-	s.assert.Equal(string(resp), "Unit dcos-notfound.service not found\n")
+	s.assertError("/system/health/v1/units/dcos-notfound.service/nodes", http.StatusNotFound, "Unit dcos-notfound.service not found")
 }
 
 func (s *HandlersTestSuit) TestgetNodeByUnitIdNodeIdHandlerFunc() {
@@ -486,12 +532,523 @@ func (s *HandlersTestSuit) TestgetNodeByUnitIdNodeIdHandlerFunc() {
 	s.assert.Equal(response, expectedResponse, "Response is in incorrect format")
 
 	// use wrong unit
-	resp = s.get("/system/health/v1/units/dcos-notfound.service/nodes/10.0.7.192")
-	s.assert.Equal(string(resp), "Unit dcos-notfound.service not found\n")
+	s.assertError("/system/health/v1/units/dcos-notfound.service/nodes/10.0.7.192", http.StatusNotFound, "Unit dcos-notfound.service not found")
 
 	// use wrong node
-	resp = s.get("/system/health/v1/units/dcos-cosmos.service/nodes/127.0.0.1")
-	s.assert.Equal(string(resp), "Node 127.0.0.1 not found\n")
+	s.assertError("/system/health/v1/units/dcos-cosmos.service/nodes/127.0.0.1", http.StatusNotFound, "Node 127.0.0.1 not found")
+}
+
+func (s *HandlersTestSuit) TestListQueryFunc() {
+	// filter nodes by role and health
+	resp := s.get("/system/health/v1/units/dcos-cosmos.service/nodes?role=agent&health=1")
+	var nodes nodesResponseJSONStruct
+	s.assert.Nil(json.Unmarshal(resp, &nodes))
+	s.assert.Equal(nodesResponseJSONStruct{
+		Array: []*nodeResponseFieldsStruct{{HostIP: "10.0.7.192", NodeHealth: 1, NodeRole: "agent"}},
+		Total: 1,
+	}, nodes)
+
+	// sort units by health in descending order
+	resp = s.get("/system/health/v1/units?sort=-health")
+	var units unitsResponseJSONStruct
+	s.assert.Nil(json.Unmarshal(resp, &units))
+	s.assert.Len(units.Array, 2)
+	s.assert.Equal("dcos-cosmos.service", units.Array[0].UnitID)
+	s.assert.Equal("dcos-adminrouter-reload.service", units.Array[1].UnitID)
+
+	// unit patterns and pagination
+	resp = s.get("/system/health/v1/units?unit=dcos-*.service&limit=1&offset=1")
+	units = unitsResponseJSONStruct{}
+	s.assert.Nil(json.Unmarshal(resp, &units))
+	s.assert.Equal(2, units.Total)
+	s.assert.Len(units.Array, 1)
+	s.assert.Equal("dcos-cosmos.service", units.Array[0].UnitID)
+
+	// health comparison
+	resp = s.get("/system/health/v1/units/dcos-cosmos.service/nodes?health%3E=1")
+	nodes = nodesResponseJSONStruct{}
+	s.assert.Nil(json.Unmarshal(resp, &nodes))
+	s.assert.Equal(1, nodes.Total)
+	resp = s.get("/system/health/v1/units/dcos-cosmos.service/nodes?host!=10.0.7.192")
+	nodes = nodesResponseJSONStruct{}
+	s.assert.Nil(json.Unmarshal(resp, &nodes))
+	s.assert.Equal(1, nodes.Total)
+	s.assert.Equal("10.0.7.193", nodes.Array[0].HostIP)
+
+	// parameters used by other handlers and a cache buster are ignored
+	for _, url := range []string{
+		"/system/health/v1/units?_=1500000000000",
+		"/system/health/v1/units?format=json",
+		"/system/health/v1/nodes?window=1h",
+	} {
+		_, code, err := MakeHTTPRequest(s.T(), s.router, url, "GET", nil)
+		s.assert.Nil(err)
+		s.assert.Equal(http.StatusOK, code, url)
+	}
+
+	// incorrect queries
+	for _, url := range []string{
+		"/system/health/v1/units?unit=%5B",
+		"/system/health/v1/nodes?role=agent,%5B",
+		"/system/health/v1/nodes?sort=color",
+		"/system/health/v1/nodes?limit=-1",
+		"/system/health/v1/nodes?health=bad",
+		"/system/health/v1/units?name>a",
+		"/system/health/v1/nodes?color=red",
+		"/system/health/v1/units?debug",
+	} {
+		_, code, err := MakeHTTPRequest(s.T(), s.router, url, "GET", nil)
+		s.assert.Nil(err)
+		s.assert.Equal(http.StatusBadRequest, code, url)
+	}
+}
+
+func (s *HandlersTestSuit) TestRequestIDFunc() {
+	req, err := http.NewRequest("GET", "/system/health/v1/nodes/127.0.0.1", nil)
+	s.assert.NoError(err)
+	req.Header.Set(requestIDHeader, "my-request")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.assert.Equal(http.StatusNotFound, w.Code)
+	s.assert.Equal("my-request", w.Header().Get(requestIDHeader))
+	s.assert.Equal("application/json", w.Header().Get("Content-Type"))
+	var response errorResponse
+	s.assert.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	s.assert.Equal(errorResponse{
+		Code:      errorCodeNotFound,
+		Message:   "Node 127.0.0.1 not found",
+		RequestID: "my-request",
+	}, response)
+
+	// a request ID is generated if not set
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/system/health/v1/nodes", nil))
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Len(w.Header().Get(requestIDHeader), 32)
+}
+
+// serve makes a request with headers and returns the response recorder.
+func (s *HandlersTestSuit) serve(url string, headers map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", url, nil)
+	s.assert.NoError(err)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// publish publishes a new snapshot of the mocked health report.
+func (s *HandlersTestSuit) publish(updated time.Time) {
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+		Units:       s.mockedMonitoringResponse.Units,
+		Nodes:       s.mockedMonitoringResponse.Nodes,
+		UpdatedTime: updated,
+	})
+}
+
+// publishWithHealth publishes a snapshot of the mocked health report with dcos-adminrouter-reload.service health
+// changed, the snapshot content differs from the mocked one.
+func (s *HandlersTestSuit) publishWithHealth(updated time.Time, health int) {
+	units := make(map[string]unit)
+	for name, u := range s.mockedMonitoringResponse.Units {
+		units[name] = u
+	}
+	u := units["dcos-adminrouter-reload.service"]
+	u.Health = health
+	units["dcos-adminrouter-reload.service"] = u
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+		Units:       units,
+		Nodes:       s.mockedMonitoringResponse.Nodes,
+		UpdatedTime: updated,
+	})
+}
+
+func (s *HandlersTestSuit) TestConditionalRequestFunc() {
+	updated := time.Date(2017, 3, 1, 10, 0, 0, 500, time.UTC)
+	s.publishWithHealth(updated.Add(-time.Hour), 1)
+	s.publish(updated)
+	version, modified, _ := globalMonitoringResponse.snapshot()
+	s.assert.Equal(updated, modified)
+
+	w := s.serve(BaseRoute+"/units", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	s.assert.Equal(snapshotETag(version, updated), etag)
+	s.assert.Equal(strconv.FormatUint(version, 10), w.Header().Get(snapshotVersionHeader))
+	s.assert.Equal("Wed, 01 Mar 2017 10:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	for _, url := range []string{BaseRoute + "/report", BaseRoute + "/units", BaseRoute + "/nodes"} {
+		w = s.serve(url, map[string]string{"If-None-Match": etag})
+		s.assert.Equal(http.StatusNotModified, w.Code, url)
+		s.assert.Empty(w.Body.String())
+
+		w = s.serve(url, map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 10:00:00 GMT"})
+		s.assert.Equal(http.StatusNotModified, w.Code, url)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	w = s.serve(BaseRoute+"/nodes", map[string]string{"If-None-Match": `"1-0"`,
+		"If-Modified-Since": "Wed, 01 Mar 2017 10:00:00 GMT"})
+	s.assert.Equal(http.StatusOK, w.Code)
+
+	w = s.serve(BaseRoute+"/nodes", map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 09:59:59 GMT"})
+	s.assert.Equal(http.StatusOK, w.Code)
+
+	// a pull with the same content does not make a new snapshot.
+	s.publish(updated.Add(time.Minute))
+	w = s.serve(BaseRoute+"/units", map[string]string{"If-None-Match": etag})
+	s.assert.Equal(http.StatusNotModified, w.Code)
+	s.assert.Equal(strconv.FormatUint(version, 10), w.Header().Get(snapshotVersionHeader))
+	s.assert.Equal("Wed, 01 Mar 2017 10:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	// a new snapshot changes the entity tag.
+	s.publishWithHealth(updated.Add(2*time.Minute), 1)
+	w = s.serve(BaseRoute+"/units", map[string]string{"If-None-Match": etag})
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal(strconv.FormatUint(version+1, 10), w.Header().Get(snapshotVersionHeader))
+
+	// error responses are not cacheable.
+	w = s.serve(BaseRoute+"/units/dcos-unknown.service", nil)
+	s.assert.Equal(http.StatusNotFound, w.Code)
+	s.assert.Empty(w.Header().Get("ETag"))
+}
+
+func (s *HandlersTestSuit) TestLongPollFunc() {
+	version, _, _ := globalMonitoringResponse.snapshot()
+	current := strconv.FormatUint(version, 10)
+
+	// a version other than the current one is served immediately.
+	w := s.serve(BaseRoute+"/units?wait="+strconv.FormatUint(version+10, 10), nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal(current, w.Header().Get(snapshotVersionHeader))
+
+	w = s.serve(BaseRoute+"/units?wait="+current+"&timeout=50ms", nil)
+	s.assert.Equal(http.StatusNotModified, w.Code)
+	s.assert.Equal(current, w.Header().Get(snapshotVersionHeader))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- s.serve(BaseRoute+"/nodes?wait="+current+"&timeout=10", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// the same content does not wake up the long-poll requests.
+	s.publish(time.Now())
+	time.Sleep(50 * time.Millisecond)
+	s.publishWithHealth(time.Now(), 1)
+
+	select {
+	case w = <-done:
+		s.assert.Equal(http.StatusOK, w.Code)
+		s.assert.Equal(strconv.FormatUint(version+1, 10), w.Header().Get(snapshotVersionHeader))
+		var response nodesResponseJSONStruct
+		s.assert.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		s.assert.Equal(1, response.Total)
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("long-poll request did not return after a new snapshot was published")
+	}
+
+	s.assertError(BaseRoute+"/units?wait=abc", http.StatusBadRequest, "wait must be a snapshot version, got abc")
+	s.assertError(BaseRoute+"/units?wait=1&timeout=1h", http.StatusBadRequest,
+		"timeout must be positive and at most 5m0s, got 1h")
+}
+
+func (s *HandlersTestSuit) TestRenderHTMLFunc() {
+	w := s.serve(BaseRoute+"/report/download?format=html", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	s.assert.Equal("attachment; filename=health-report.html", w.Header().Get("Content-disposition"))
+
+	body := w.Body.String()
+	s.assert.Contains(body, "<h3>10.0.7.192 (, agent)</h3>")
+	s.assert.Contains(body, "<pre>Some nasty error occured</pre>")
+	s.assert.NotContains(body, "<link")
+	s.assert.NotContains(body, "<script")
+	// only the failing units are listed by node.
+	s.assert.NotContains(body, "<h3>10.0.7.190")
+
+	// a node without failing units.
+	w = s.serve(BaseRoute+"/nodes?format=html", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Contains(w.Body.String(), "All units are healthy.")
+	s.assert.Contains(w.Body.String(), "<td>10.0.7.190</td>")
+}
+
+func (s *HandlersTestSuit) TestRenderCSVFunc() {
+	w := s.serve(BaseRoute+"/units?format=csv", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal("text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	s.assert.NoError(err)
+	// a header and a row per unit per node.
+	s.assert.Len(records, 5)
+	s.assert.Equal("unit_id", records[0][0])
+	s.assert.Equal([]string{"dcos-cosmos.service", "Package Service", "10.0.7.192"}, records[3][:3])
+	s.assert.Equal("1", records[3][7])
+	s.assert.Equal("Some nasty error occured", records[3][8])
+
+	// the filters apply to the rendered units.
+	w = s.serve(BaseRoute+"/units?format=csv&health=1", nil)
+	records, err = csv.NewReader(w.Body).ReadAll()
+	s.assert.NoError(err)
+	s.assert.Len(records, 3)
+
+	// cells a spreadsheet would evaluate as formulas are quoted.
+	for cell, expected := range map[string]string{
+		"=HYPERLINK(\"http://example.com\")": "'=HYPERLINK(\"http://example.com\")",
+		"+1":                                 "'+1",
+		"-1":                                 "'-1",
+		"@SUM(A1)":                           "'@SUM(A1)",
+		"exit status 1":                      "exit status 1",
+		"":                                   "",
+	} {
+		s.assert.Equal(expected, csvCell(cell))
+	}
+}
+
+func (s *HandlersTestSuit) TestRenderMarkdownFunc() {
+	w := s.serve(BaseRoute+"/report?format=markdown", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal("text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	s.assert.Contains(body, "### 10.0.7.192 (, agent)\n\n#### dcos-cosmos.service (Package Service)\n\n"+
+		"```\nSome nasty error occured\n```")
+	s.assert.Contains(body, "| dcos-cosmos.service | Package Service | Unhealthy | 1/2 | DCOS Packaging API |")
+	s.assert.Contains(body, "| 10.0.7.190 |  | master | Healthy | 0/1 |")
+
+	s.assertError(BaseRoute+"/report?format=pdf", http.StatusBadRequest,
+		"Incorrect format pdf, must be: json, html, markdown or csv")
+}
+
+// nodeLogServer starts a fake 3dt on a node serving the logs and adds the node to the health report.
+func (s *HandlersTestSuit) nodeLogServer(release <-chan struct{}) *httptest.Server {
+	var port int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case BaseRoute + "/logs":
+			json.NewEncoder(w).Encode(map[string]string{
+				"dcos-cosmos.service": fmt.Sprintf(":%d%s/logs/units/dcos-cosmos.service", port, BaseRoute),
+				"mesos-agent":         ":5051/files/download?path=/var/log/mesos/mesos-agent.log",
+			})
+		case BaseRoute + "/logs/units/dcos-cosmos.service":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("first line\n"))
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("last line\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	u, err := url.Parse(server.URL)
+	s.assert.NoError(err)
+	port, err = strconv.Atoi(u.Port())
+	s.assert.NoError(err)
+
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+		Units: s.mockedMonitoringResponse.Units,
+		Nodes: map[string]Node{
+			"127.0.0.1": {IP: "127.0.0.1", Role: AgentRole, Host: "agent01", MesosID: "agent-1", Port: port},
+		},
+	})
+	return server
+}
+
+func (s *HandlersTestSuit) TestNodeLogsProxyFunc() {
+	release := make(chan struct{})
+	close(release)
+	server := s.nodeLogServer(release)
+	defer server.Close()
+
+	w := s.serve(BaseRoute+"/nodes/agent-1/logs", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	var logs map[string]string
+	s.assert.NoError(json.Unmarshal(w.Body.Bytes(), &logs))
+	s.assert.Equal(map[string]string{
+		"dcos-cosmos.service": BaseRoute + "/nodes/agent-1/logs/units/dcos-cosmos.service",
+		"mesos-agent":         ":5051/files/download?path=/var/log/mesos/mesos-agent.log",
+	}, logs)
+
+	w = s.serve(BaseRoute+"/nodes/agent01/logs/units/dcos-cosmos.service", nil)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal([]string{"text/html"}, w.Header()["Content-Type"])
+	s.assert.Equal("first line\nlast line\n", w.Body.String())
+
+	// the node errors are passed through.
+	w = s.serve(BaseRoute+"/nodes/127.0.0.1/logs/units/dcos-unknown.service", nil)
+	s.assert.Equal(http.StatusNotFound, w.Code)
+
+	s.assertError(BaseRoute+"/nodes/10.0.0.9/logs", http.StatusNotFound, "Node 10.0.0.9 not found")
+
+	server.Close()
+	w = s.serve(BaseRoute+"/nodes/127.0.0.1/logs/units/dcos-cosmos.service", nil)
+	s.assert.Equal(http.StatusServiceUnavailable, w.Code)
+	s.assert.Contains(w.Body.String(), "Could not reach node 127.0.0.1")
+}
+
+func (s *HandlersTestSuit) TestNodeLogStreamingFunc() {
+	release := make(chan struct{})
+	server := s.nodeLogServer(release)
+	defer server.Close()
+	master := httptest.NewServer(s.router)
+	defer master.Close()
+
+	resp, err := http.Get(master.URL + BaseRoute + "/nodes/agent-1/logs/units/dcos-cosmos.service")
+	s.assert.NoError(err)
+	defer resp.Body.Close()
+
+	// the first line is proxied before the node finishes the log.
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	s.assert.NoError(err)
+	s.assert.Equal("first line\n", line)
+	close(release)
+}
+
+// TestOpenAPIFunc checks the OpenAPI description shipped with the client package describes every route.
+func (s *HandlersTestSuit) TestOpenAPIFunc() {
+	f, err := os.Open("../client/openapi.json")
+	s.assert.NoError(err)
+	defer f.Close()
+
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	s.assert.NoError(json.NewDecoder(f).Decode(&spec))
+
+	cfg := testCfg
+	dt := Dt{Cfg: &cfg, DtDCOSTools: &fakeDCOSTools{}, DtFederation: &Federation{}}
+	routes := make(map[string]bool)
+	for _, route := range getRoutes(dt) {
+		methods := route.methods
+		if len(methods) == 0 {
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			routes[route.url+" "+method] = true
+			_, ok := spec.Paths[route.url][strings.ToLower(method)]
+			s.assert.True(ok, "%s %s is not described", method, route.url)
+		}
+	}
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			s.assert.True(routes[path+" "+strings.ToUpper(method)], "%s %s is not routed", method, path)
+		}
+	}
+}
+
+func (s *HandlersTestSuit) TestMetricsFunc() {
+	globalMonitoringResponse.Lock()
+	globalMonitoringResponse.UpdatedTime = time.Now()
+	globalMonitoringResponse.Unlock()
+
+	cfg := testCfg
+	cfg.FlagPull = true
+	router := NewRouter(Dt{Cfg: &cfg, DtDCOSTools: &fakeDCOSTools{}, SystemdUnits: &SystemdUnits{}})
+	req := httptest.NewRequest("GET", "/system/health/v1/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.Equal(metricsContentType, w.Header().Get("Content-type"))
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE dcos_3dt_unit_health gauge",
+		`dcos_3dt_unit_health{unit="unit_a",name="PrettyName"} 0`,
+		`dcos_3dt_load_average{period="1m"}`,
+		`dcos_3dt_cluster_unit_health{unit="dcos-cosmos.service"} 1`,
+		`dcos_3dt_cluster_unit_node_health{unit="dcos-adminrouter-reload.service",node="10.0.7.190",role="master",host=""} 0`,
+		`dcos_3dt_cluster_unit_node_health{unit="dcos-cosmos.service",node="10.0.7.192",role="agent",host=""} 1`,
+		`dcos_3dt_cluster_node_health{node="10.0.7.190",role="master",host=""} 0`,
+		"# TYPE dcos_3dt_cluster_report_age_seconds gauge",
+	} {
+		s.assert.Contains(body, line)
+	}
+	s.assert.NotContains(body, "unit_to_fail")
+
+	// agents do not expose the cluster health
+	router = NewRouter(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}, SystemdUnits: &SystemdUnits{}})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	s.assert.Equal(http.StatusOK, w.Code)
+	s.assert.NotContains(w.Body.String(), "dcos_3dt_cluster_")
+
+	// label values are escaped
+	f := newMetricFamily("test", "Test metric.")
+	f.add(1.5, "label", "a \"quoted\"\nvalue")
+	var buf bytes.Buffer
+	s.assert.NoError(writeMetrics(&buf, []*metricFamily{f, newMetricFamily("empty", "No samples.")}))
+	s.assert.Equal("# HELP dcos_3dt_test Test metric.\n# TYPE dcos_3dt_test gauge\n"+
+		`dcos_3dt_test{label="a \"quoted\"\nvalue"} 1.5`+"\n", buf.String())
+}
+
+func (s *HandlersTestSuit) TestSummaryFunc() {
+	summary := func(policy string) (summaryResponseJSONStruct, int) {
+		cfg := testCfg
+		cfg.FlagSummaryPolicy = policy
+		response, code, err := MakeHTTPRequest(s.T(), NewRouter(Dt{Cfg: &cfg}), "/system/health/v1/summary", "GET", nil)
+		s.assert.NoError(err)
+		var summary summaryResponseJSONStruct
+		s.assert.NoError(json.Unmarshal(response, &summary))
+		return summary, code
+	}
+
+	// the report was not pulled yet
+	response, code := summary(SummaryPolicyNone)
+	s.assert.Equal(http.StatusServiceUnavailable, code)
+	s.assert.Equal(summaryStatusUnknown, response.Status)
+	s.assert.Nil(response.UpdatedTime)
+
+	globalMonitoringResponse.Lock()
+	globalMonitoringResponse.UpdatedTime = time.Now()
+	globalMonitoringResponse.Unlock()
+
+	// dcos-cosmos.service is unhealthy on an agent only
+	response, code = summary(SummaryPolicyMasters)
+	s.assert.Equal(http.StatusOK, code)
+	s.assert.Equal(summaryStatusDegraded, response.Status)
+	s.assert.Equal(healthCounts{Total: 2, Healthy: 1, Unhealthy: 1}, response.Units)
+	s.assert.Equal(map[string]healthCounts{MasterRole: {Total: 1, Healthy: 1}}, response.Nodes)
+	s.assert.Equal([]failingUnit{{
+		ID:     "dcos-cosmos.service",
+		Name:   "Package Service",
+		Health: 1,
+		Title:  "DCOS Packaging API",
+		Nodes:  []string{"10.0.7.192"},
+	}}, response.FailingUnits)
+
+	response, code = summary(SummaryPolicyAny)
+	s.assert.Equal(http.StatusServiceUnavailable, code)
+	s.assert.Equal(summaryStatusUnhealthy, response.Status)
+	s.assert.Equal(SummaryPolicyAny, response.Policy)
+
+	// an unhealthy unit on a master fails the default policy
+	globalMonitoringResponse.Lock()
+	cosmos := globalMonitoringResponse.Units["dcos-cosmos.service"]
+	cosmos.Nodes = append(cosmos.Nodes, Node{IP: "10.0.7.194", Role: MasterRole, Health: 1})
+	globalMonitoringResponse.Units["dcos-cosmos.service"] = cosmos
+	globalMonitoringResponse.Unlock()
+	response, code = summary("")
+	s.assert.Equal(http.StatusServiceUnavailable, code)
+	s.assert.Equal(SummaryPolicyMasters, response.Policy)
+	s.assert.Equal([]string{"10.0.7.192", "10.0.7.194"}, response.FailingUnits[0].Nodes)
+
+	// a report older than the max report age fails any policy
+	globalMonitoringResponse.Lock()
+	globalMonitoringResponse.UpdatedTime = time.Now().Add(-time.Duration(testCfg.FlagSummaryMaxAgeSec+1) * time.Second)
+	globalMonitoringResponse.Unlock()
+	response, code = summary(SummaryPolicyNone)
+	s.assert.Equal(http.StatusServiceUnavailable, code)
+	s.assert.Equal(summaryStatusUnknown, response.Status)
+	s.assert.NotNil(response.UpdatedTime)
+	s.assert.True(response.ReportAgeSeconds > float64(testCfg.FlagSummaryMaxAgeSec))
 }
 
 func (s *HandlersTestSuit) TestgetNodesHandlerFunc() {
@@ -525,8 +1082,7 @@ func (s *HandlersTestSuit) TestgetNodeByIdHandlerFunc() {
 	})
 
 	// use wrong host
-	resp = s.get("/system/health/v1/nodes/127.0.0.1")
-	s.assert.Equal(string(resp), "Node 127.0.0.1 not found\n")
+	s.assertError("/system/health/v1/nodes/127.0.0.1", http.StatusNotFound, "Node 127.0.0.1 not found")
 }
 
 func (s *HandlersTestSuit) TestgetNodeUnitsByNodeIdHandlerFunc() {
@@ -545,8 +1101,7 @@ func (s *HandlersTestSuit) TestgetNodeUnitsByNodeIdHandlerFunc() {
 	})
 
 	// use wrong host
-	resp = s.get("/system/health/v1/nodes/127.0.0.1/units")
-	s.assert.Equal(string(resp), "Node 127.0.0.1 not found\n")
+	s.assertError("/system/health/v1/nodes/127.0.0.1/units", http.StatusNotFound, "Node 127.0.0.1 not found")
 }
 
 func (s *HandlersTestSuit) TestgetNodeUnitByNodeIdUnitIdHandlerFunc() {
@@ -563,12 +1118,10 @@ func (s *HandlersTestSuit) TestgetNodeUnitByNodeIdUnitIdHandlerFunc() {
 	})
 
 	// use wrong host
-	resp = s.get("/system/health/v1/nodes/127.0.0.1/units/dcos-adminrouter-reload.service")
-	s.assert.Equal(string(resp), "Node 127.0.0.1 not found\n")
+	s.assertError("/system/health/v1/nodes/127.0.0.1/units/dcos-adminrouter-reload.service", http.StatusNotFound, "Node 127.0.0.1 not found")
 
 	// use wrong service
-	resp = s.get("/system/health/v1/nodes/10.0.7.190/units/dcos-bad.service")
-	s.assert.Equal(string(resp), "Unit dcos-bad.service not found\n")
+	s.assertError("/system/health/v1/nodes/10.0.7.190/units/dcos-bad.service", http.StatusNotFound, "Unit dcos-bad.service not found")
 }
 
 func (s *HandlersTestSuit) TestreportHandlerFunc() {
@@ -581,6 +1134,101 @@ func (s *HandlersTestSuit) TestreportHandlerFunc() {
 	s.assert.Len(response.Nodes, 1)
 }
 
+// push posts a health report from a remote address.
+func (s *HandlersTestSuit) push(remoteAddr, token string, body io.Reader) (diagnosticsReportResponse, int) {
+	req, err := http.NewRequest("POST", "/system/health/v1/push", body)
+	s.assert.NoError(err)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set(pushTokenHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var response diagnosticsReportResponse
+	s.assert.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	return response, w.Code
+}
+
+func (s *HandlersTestSuit) TestPushHandlerFunc() {
+	// Test endpoint /system/health/v1/push, 127.0.0.2 is a discovered agent.
+	body := `{"units": [{"id": "dcos-pushed.service", "health": 1}], "ip": "127.0.0.2", "node_role": "master"}`
+	response, code := s.push("127.0.0.2:41000", "", bytes.NewBufferString(body))
+	s.assert.Equal(code, http.StatusOK)
+	s.assert.Equal(response.Status, "Health report accepted from 127.0.0.2")
+
+	// 3dt was started without -pull flag, the pushed report should be merged immediately.
+	unit, err := globalMonitoringResponse.GetUnit("dcos-pushed.service")
+	s.assert.Nil(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+
+	// the role is set by the discovery.
+	node, err := globalMonitoringResponse.GetNodeByID("127.0.0.2")
+	s.assert.Nil(err)
+	s.assert.Equal(AgentRole, node.NodeRole)
+
+	// a node cannot push a report of another node.
+	response, code = s.push("127.0.0.2:41000", "", bytes.NewBufferString(`{"ip": "127.0.0.1"}`))
+	s.assert.Equal(http.StatusForbidden, code)
+	s.assert.Equal("forbidden", response.Code)
+	s.assert.Equal("Node 127.0.0.2 cannot push a health report of 127.0.0.1", response.Status)
+
+	// a node not found by the discovery.
+	response, code = s.push("10.0.7.200:41000", "", bytes.NewBufferString(`{}`))
+	s.assert.Equal(http.StatusForbidden, code)
+	s.assert.Equal("Node 10.0.7.200 is not found by the discovery", response.Status)
+
+	// malformed report
+	_, code = s.push("127.0.0.2:41000", "", bytes.NewBufferString("{"))
+	s.assert.Equal(code, http.StatusBadRequest)
+
+	// a report is limited in size.
+	_, code = s.push("127.0.0.2:41000", "", strings.NewReader(`{"hostname": "`+strings.Repeat("a", maxPushedReportSize)+`"}`))
+	s.assert.Equal(code, http.StatusBadRequest)
+}
+
+func (s *HandlersTestSuit) TestPushHandlerTokenFunc() {
+	s.dt.Cfg.FlagPushToken = "secret"
+	defer func() { s.dt.Cfg.FlagPushToken = "" }()
+	s.dt.DtDCOSTools.(*fakeDCOSTools).fakeAgents = []Node{{IP: "127.0.0.2", Role: AgentRole, MesosID: "agent-123"}}
+
+	// an agent behind NAT pushes from the gateway address, the node is found by the Mesos ID.
+	body := `{"units": [{"id": "dcos-nat.service", "health": 1}], "ip": "192.168.0.5", "mesos_id": "agent-123"}`
+	response, code := s.push("10.0.0.1:41000", "secret", bytes.NewBufferString(body))
+	s.assert.Equal(http.StatusOK, code)
+	s.assert.Equal("Health report accepted from 127.0.0.2", response.Status)
+
+	node, err := globalMonitoringResponse.GetNodeByID("127.0.0.2")
+	s.assert.Nil(err)
+	s.assert.Equal(AgentRole, node.NodeRole)
+
+	// without a Mesos ID the node is found by the IP address in the report.
+	response, code = s.push("10.0.0.1:41000", "secret", bytes.NewBufferString(`{"ip": "127.0.0.2"}`))
+	s.assert.Equal(http.StatusOK, code)
+	s.assert.Equal("Health report accepted from 127.0.0.2", response.Status)
+
+	// a missing or wrong token.
+	for _, token := range []string{"", "wrong"} {
+		response, code = s.push("127.0.0.2:41000", token, bytes.NewBufferString(`{"ip": "127.0.0.2"}`))
+		s.assert.Equal(http.StatusForbidden, code)
+		s.assert.Equal("forbidden", response.Code)
+	}
+
+	// a node not found by the discovery.
+	response, code = s.push("10.0.0.1:41000", "secret", bytes.NewBufferString(`{"mesos_id": "agent-404"}`))
+	s.assert.Equal(http.StatusForbidden, code)
+	s.assert.Equal("Node agent-404 is not found by the discovery", response.Status)
+
+	// a report without a node identity.
+	_, code = s.push("10.0.0.1:41000", "secret", bytes.NewBufferString(`{}`))
+	s.assert.Equal(http.StatusBadRequest, code)
+}
+
+func (s *HandlersTestSuit) TestKnownNodesUnavailableFunc() {
+	// 3dt was started without a node tracker.
+	s.assertError("/system/health/v1/known-nodes", http.StatusServiceUnavailable, "Node tracker is not available")
+}
+
 func (s *HandlersTestSuit) TestIsInListFunc() {
 	array := []string{"DC", "OS", "SYS"}
 	s.assert.Equal(isInList("DC", array), true, "DC should be in test array")
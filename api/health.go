@@ -14,8 +14,9 @@ type SystemdUnits struct {
 	sync.Mutex
 }
 
-// GetUnitsProperties return a structured units health response of UnitsHealthResponseJsonStruct type.
-func (s *SystemdUnits) GetUnitsProperties(cfg *Config, tools DCOSHelper) (healthReport UnitsHealthResponseJSONStruct, err error) {
+// GetUnitsProperties return a structured units health response of UnitsHealthResponseJsonStruct type. The help of
+// the unhealthy units is looked up in the knowledge base.
+func (s *SystemdUnits) GetUnitsProperties(cfg *Config, tools DCOSHelper, kb *KnowledgeBase) (healthReport UnitsHealthResponseJSONStruct, err error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -61,6 +62,8 @@ func (s *SystemdUnits) GetUnitsProperties(cfg *Config, tools DCOSHelper) (health
 			logrus.Errorf("Could not normalize property for unit %s: %s", unit, err)
 			continue
 		}
+		normalizedProperty.Help = kb.Help(normalizedProperty.UnitID, normalizedProperty.UnitHealth,
+			normalizedProperty.UnitOutput)
 		allUnitsProperties = append(allUnitsProperties, normalizedProperty)
 	}
 	// after we finished querying systemd units, close dbus connection
@@ -2,13 +2,13 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/coreos/go-systemd/dbus"
+	"github.com/dcos/3dt/client"
 	"io"
 	"io/ioutil"
 	"net"
@@ -29,11 +29,23 @@ type DCOSTools struct {
 	sync.Mutex
 	ExhibitorURL string
 	ForceTLS     bool
-	dcon         *dbus.Conn
-	hostname     string
-	role         string
-	ip           string
-	mesosID      string
+
+	// nodes discovery
+	DiscoveryChain    *DiscoveryChain
+	DiscoveryFile     string
+	DiscoveryPosition string
+	StaticNodes       []StaticNode
+
+	// discovered nodes cache
+	DiscoveryCacheTTL   time.Duration
+	DiscoveryCacheStale time.Duration
+	discoveryCache      discoveryCache
+
+	dcon     *dbus.Conn
+	hostname string
+	role     string
+	ip       string
+	mesosID  string
 }
 
 // GetHostname return a localhost hostname.
@@ -251,14 +263,17 @@ func isInList(item string, l []string) bool {
 }
 
 func (st *DCOSTools) doRequest(method, url string, timeout time.Duration, body io.Reader) (responseBody []byte, httpResponseCode int, err error) {
-	if url != st.ExhibitorURL {
-		url, err = useTLSScheme(url, st.ForceTLS)
-		if err != nil {
-			return responseBody, http.StatusBadRequest, err
-		}
+	return st.doRequestWithScheme(method, url, timeout, body, st.ForceTLS && url != st.ExhibitorURL)
+}
+
+// doRequestWithScheme makes an HTTP request, the URL scheme is changed to https if forceTLS is true.
+func (st *DCOSTools) doRequestWithScheme(method, url string, timeout time.Duration, body io.Reader, forceTLS bool) (responseBody []byte, httpResponseCode int, err error) {
+	url, err = useTLSScheme(url, forceTLS)
+	if err != nil {
+		return responseBody, http.StatusBadRequest, err
 	}
 
-	log.Debugf("[%s] %s, timeout: %s, forceTLS: %v, basicURL: %s", method, url, timeout.String(), st.ForceTLS, url)
+	log.Debugf("[%s] %s, timeout: %s, forceTLS: %v, basicURL: %s", method, url, timeout.String(), forceTLS, url)
 	request, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return responseBody, http.StatusBadRequest, err
@@ -279,6 +294,11 @@ func (st *DCOSTools) Get(url string, timeout time.Duration) (body []byte, httpRe
 	return st.doRequest("GET", url, timeout, nil)
 }
 
+// getExhibitor makes HTTP GET request to exhibitor, exhibitor does not support TLS.
+func (st *DCOSTools) getExhibitor(url string, timeout time.Duration) (body []byte, httpResponseCode int, err error) {
+	return st.doRequestWithScheme("GET", url, timeout, nil, false)
+}
+
 // Post HTTP request.
 func (st *DCOSTools) Post(url string, timeout time.Duration) (body []byte, httpResponseCode int, err error) {
 	return st.doRequest("POST", url, timeout, nil)
@@ -291,78 +311,59 @@ func (st *DCOSTools) GetTimestamp() time.Time {
 
 // GetMasterNodes finds DC/OS masters.
 func (st *DCOSTools) GetMasterNodes() (nodesResponse []Node, err error) {
-	finder := &findMastersInExhibitor{
-		url:   st.ExhibitorURL,
-		getFn: st.Get,
-		next: &findNodesInDNS{
-			forceTLS:  st.ForceTLS,
-			dnsRecord: "master.mesos",
-			role:      MasterRole,
-			next:      nil,
-		},
-	}
-	return finder.find()
+	return st.cachedDiscover(MasterRole)
 }
 
 // GetAgentNodes finds DC/OS agents.
 func (st *DCOSTools) GetAgentNodes() (nodes []Node, err error) {
-	finder := &findNodesInDNS{
-		forceTLS:  st.ForceTLS,
-		dnsRecord: "leader.mesos",
-		role:      AgentRole,
-		getFn:     st.Get,
-		next: &findAgentsInHistoryService{
-			pastTime: "/minute/",
-			next: &findAgentsInHistoryService{
-				pastTime: "/hour/",
-				next:     nil,
-			},
-		},
-	}
-	return finder.find()
+	return st.cachedDiscover(AgentRole)
 }
 
 // NewHTTPClient creates a new instance of http.Client
 func NewHTTPClient(timeout time.Duration, transport *http.Transport) *http.Client {
-	client := http.Client{
-		Timeout: timeout,
-	}
-
-	if transport != nil {
-		client.Transport = transport
-	}
-
-	// go http client does not copy the headers when it follows the redirect.
-	// https://github.com/golang/go/issues/4800
-	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		for attr, val := range via[0].Header {
-			if _, ok := req.Header[attr]; !ok {
-				req.Header[attr] = val
-			}
-		}
-		return nil
-	}
-
-	return &client
+	return client.NewHTTPClient(timeout, transport)
 }
 
 // NewSecureTransport creates a new instance of http.Transport
 func NewSecureTransport(caPool *x509.CertPool) *http.Transport {
-	var tlsClientConfig *tls.Config
-	if caPool == nil {
-		// do HTTPS without certificate verification.
-		tlsClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
-	} else {
-		tlsClientConfig = &tls.Config{
-			RootCAs: caPool,
-		}
+	return client.NewSecureTransport(caPool)
+}
+
+// dcosToolsRequester is a client.Requester making GET and POST requests without a body with DCOSHelper. DCOSHelper
+// sets its own headers, a request with a body or headers is rejected rather than sent without them.
+type dcosToolsRequester struct {
+	DCOSTools DCOSHelper
+}
+
+// Do makes a request and returns a response with the read body.
+func (r dcosToolsRequester) Do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil, fmt.Errorf("%s %s: a request body is not supported", req.Method, req.URL)
+	}
+	if len(req.Header) > 0 {
+		return nil, fmt.Errorf("%s %s: request headers are not supported", req.Method, req.URL)
 	}
 
-	return &http.Transport{
-		TLSClientConfig: tlsClientConfig,
+	var do func(string, time.Duration) ([]byte, int, error)
+	switch req.Method {
+	case "GET":
+		do = r.DCOSTools.Get
+	case "POST":
+		do = r.DCOSTools.Post
+	default:
+		return nil, fmt.Errorf("Method %s is not supported", req.Method)
+	}
+
+	body, statusCode, err := do(req.URL.String(), timeout)
+	if err != nil {
+		return nil, err
 	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
 }
 
 // HTTPReq is an implementation of HTTPRequester interface
@@ -400,27 +401,7 @@ func (h *HTTPReq) Do(req *http.Request, timeout time.Duration) (resp *http.Respo
 }
 
 func loadCAPool(config *Config) (*x509.CertPool, error) {
-	// If no ca found, return nil.
-	if config.FlagCACertFile == "" {
-		return nil, nil
-	}
-
-	caPool := x509.NewCertPool()
-	f, err := os.Open(config.FlagCACertFile)
-	if err != nil {
-		return caPool, err
-	}
-	defer f.Close()
-
-	b, err := ioutil.ReadAll(f)
-	if err != nil {
-		return caPool, err
-	}
-
-	if !caPool.AppendCertsFromPEM(b) {
-		return caPool, errors.New("CACertFile parsing failed")
-	}
-	return caPool, nil
+	return client.LoadCAPool(config.FlagCACertFile)
 }
 
 // Do makes an HTTP(S) request with predefined http.Request object.
@@ -431,9 +412,9 @@ func Do(req *http.Request, timeout time.Duration, headers map[string]string, tra
 		req.Header.Add(headerKey, headerValue)
 	}
 
-	client := NewHTTPClient(timeout, transport)
+	httpClient := NewHTTPClient(timeout, transport)
 
-	resp, err = client.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return resp, err
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
@@ -85,6 +86,32 @@ func (s *HelpersTestSuit) TestRunCmdStdout() {
 	s.assert.NotEmpty(stdoutBuf.String())
 }
 
+func (s *HelpersTestSuit) TestDCOSToolsRequester() {
+	st := &fakeDCOSTools{}
+	requester := dcosToolsRequester{st}
+
+	req, err := http.NewRequest("POST", "http://127.0.0.1:1050"+BaseRoute+"/report/diagnostics/cancel", nil)
+	s.assert.NoError(err)
+	resp, err := requester.Do(req, time.Second)
+	s.assert.NoError(err)
+	resp.Body.Close()
+	s.assert.Equal([]string{"http://127.0.0.1:1050" + BaseRoute + "/report/diagnostics/cancel"}, st.postRequestsMade)
+
+	// a body or headers would be dropped, the request is rejected
+	req, err = http.NewRequest("POST", "http://127.0.0.1:1050"+BaseRoute+"/report/diagnostics/create",
+		bytes.NewReader([]byte(`{"nodes": ["all"]}`)))
+	s.assert.NoError(err)
+	_, err = requester.Do(req, time.Second)
+	s.assert.Error(err)
+
+	req, err = http.NewRequest("GET", "http://127.0.0.1:1050"+BaseRoute, nil)
+	s.assert.NoError(err)
+	req.Header.Set("Authorization", "token=abc")
+	_, err = requester.Do(req, time.Second)
+	s.assert.Error(err)
+	s.assert.Empty(st.getRequestsMade)
+}
+
 // Run test suit
 func TestHelpersTestSuit(t *testing.T) {
 	suite.Run(t, new(HelpersTestSuit))
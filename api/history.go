@@ -0,0 +1,337 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// HealthHistory keeps a downsampled time series of unit and node health. The series and the latest report are
+// persisted to disk and reloaded at startup, so the API can serve the cluster health right after a restart.
+// The report is sampled and persisted at most once per pull interval, pushed reports update the cluster report
+// on every push.
+type HealthHistory struct {
+	sync.RWMutex
+	path       string
+	resolution time.Duration
+	retention  time.Duration
+	interval   time.Duration
+
+	Units map[string]*healthSeries `json:"units"`
+	Nodes map[string]*healthSeries `json:"nodes"`
+
+	// Last is the latest aggregated report.
+	Last *historySnapshot `json:"last,omitempty"`
+}
+
+type historySnapshot struct {
+	Units       map[string]unit
+	Nodes       map[string]Node
+	UpdatedTime time.Time
+}
+
+// healthSeries is a list of health buckets sorted by the bucket start time.
+type healthSeries struct {
+	Buckets []healthBucket `json:"buckets"`
+}
+
+// healthBucket counts health samples collected within a history resolution interval.
+type healthBucket struct {
+	Start   time.Time `json:"start"`
+	Healthy int       `json:"healthy"`
+	Samples int       `json:"samples"`
+}
+
+// availabilityResponse is a response for unit and node availability requests.
+type availabilityResponse struct {
+	ID                  string    `json:"id"`
+	Window              string    `json:"window"`
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	AvailabilityPercent float64   `json:"availability_percent"`
+	Samples             int       `json:"samples"`
+	HealthySamples      int       `json:"healthy_samples"`
+}
+
+// availabilityReportResponse is a response with availability of all units and nodes.
+type availabilityReportResponse struct {
+	Units []availabilityResponse `json:"units"`
+	Nodes []availabilityResponse `json:"nodes"`
+}
+
+func (s *healthSeries) add(t time.Time, healthy bool, resolution time.Duration) {
+	start := t.Truncate(resolution)
+	if len(s.Buckets) == 0 || !s.Buckets[len(s.Buckets)-1].Start.Equal(start) {
+		s.Buckets = append(s.Buckets, healthBucket{Start: start})
+	}
+	bucket := &s.Buckets[len(s.Buckets)-1]
+	bucket.Samples++
+	if healthy {
+		bucket.Healthy++
+	}
+}
+
+// prune removes the buckets started before t.
+func (s *healthSeries) prune(t time.Time) {
+	var i int
+	for i < len(s.Buckets) && s.Buckets[i].Start.Before(t) {
+		i++
+	}
+	s.Buckets = s.Buckets[i:]
+}
+
+// count returns a number of healthy samples and a number of all samples collected since t.
+func (s *healthSeries) count(t time.Time, resolution time.Duration) (healthy int, samples int) {
+	for _, bucket := range s.Buckets {
+		// include the bucket t belongs to.
+		if bucket.Start.Add(resolution).After(t) {
+			healthy += bucket.Healthy
+			samples += bucket.Samples
+		}
+	}
+	return healthy, samples
+}
+
+// Init sets the history parameters from a config and loads the persisted history. If 3dt pulls the cluster,
+// the persisted report is loaded into globalMonitoringResponse.
+func (h *HealthHistory) Init(config *Config) error {
+	h.Lock()
+	h.path = config.FlagHistoryFile
+	h.resolution = time.Duration(config.FlagHistoryResolutionMinutes) * time.Minute
+	h.retention = time.Duration(config.FlagHistoryRetentionDays) * 24 * time.Hour
+	h.interval = time.Duration(config.FlagPullInterval) * time.Second
+	h.Units = make(map[string]*healthSeries)
+	h.Nodes = make(map[string]*healthSeries)
+	h.Unlock()
+
+	if err := h.load(); err != nil {
+		return err
+	}
+
+	h.RLock()
+	defer h.RUnlock()
+	if config.FlagPull && h.Last != nil {
+		logrus.Infof("Loaded a health report updated at %s from %s", h.Last.UpdatedTime, h.path)
+		globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+			Units:       h.Last.Units,
+			Nodes:       h.Last.Nodes,
+			UpdatedTime: h.Last.UpdatedTime,
+		})
+	}
+	return nil
+}
+
+func (h *HealthHistory) load() error {
+	if h.path == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logrus.Infof("%s not found, starting a new health history", h.path)
+			return nil
+		}
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	if err := json.Unmarshal(content, h); err != nil {
+		return err
+	}
+	if h.Units == nil {
+		h.Units = make(map[string]*healthSeries)
+	}
+	if h.Nodes == nil {
+		h.Nodes = make(map[string]*healthSeries)
+	}
+	return nil
+}
+
+// save writes the history to a temporary file and renames it, so the history file is never partially written.
+func (h *HealthHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	h.RLock()
+	content, err := json.Marshal(h)
+	h.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(h.path, content)
+}
+
+// writeFileAtomic writes content to a temporary file and renames it to path.
+func writeFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// record adds the current globalMonitoringResponse to the history and persists it, unless the last sample was taken
+// less than a pull interval ago.
+func (h *HealthHistory) record() {
+	if h == nil {
+		return
+	}
+
+	globalMonitoringResponse.RLock()
+	snapshot := &historySnapshot{
+		Units:       globalMonitoringResponse.Units,
+		Nodes:       globalMonitoringResponse.Nodes,
+		UpdatedTime: globalMonitoringResponse.UpdatedTime,
+	}
+	globalMonitoringResponse.RUnlock()
+
+	h.Lock()
+	// do not count the same report twice, e.g. when the leading master did not update its report.
+	if snapshot.UpdatedTime.IsZero() || (h.Last != nil && !snapshot.UpdatedTime.After(h.Last.UpdatedTime)) {
+		h.Unlock()
+		return
+	}
+	if h.Last != nil && snapshot.UpdatedTime.Sub(h.Last.UpdatedTime) < h.interval {
+		h.Unlock()
+		return
+	}
+
+	for name, u := range snapshot.Units {
+		if _, ok := h.Units[name]; !ok {
+			h.Units[name] = &healthSeries{}
+		}
+		h.Units[name].add(snapshot.UpdatedTime, u.Health == 0, h.resolution)
+	}
+	for ip, node := range snapshot.Nodes {
+		if _, ok := h.Nodes[ip]; !ok {
+			h.Nodes[ip] = &healthSeries{}
+		}
+		h.Nodes[ip].add(snapshot.UpdatedTime, node.Health == 0, h.resolution)
+	}
+	h.Last = snapshot
+	h.prune(snapshot.UpdatedTime.Add(-h.retention))
+	h.Unlock()
+
+	if err := h.save(); err != nil {
+		logrus.Errorf("Could not save health history to %s: %s", h.path, err)
+	}
+}
+
+// prune removes the buckets started before t and the series without buckets. The caller must hold the lock.
+func (h *HealthHistory) prune(t time.Time) {
+	for _, series := range []map[string]*healthSeries{h.Units, h.Nodes} {
+		for key, s := range series {
+			s.prune(t)
+			if len(s.Buckets) == 0 {
+				delete(series, key)
+			}
+		}
+	}
+}
+
+func (h *HealthHistory) availability(series map[string]*healthSeries, id string, window time.Duration) (availabilityResponse, error) {
+	s, ok := series[id]
+	if !ok {
+		return availabilityResponse{}, notFoundError("%s not found in health history", id)
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	healthy, samples := s.count(from, h.resolution)
+	response := availabilityResponse{
+		ID:             id,
+		Window:         window.String(),
+		From:           from,
+		To:             to,
+		Samples:        samples,
+		HealthySamples: healthy,
+	}
+	if samples == 0 {
+		return response, notFoundError("no health samples collected for %s in the past %s", id, window)
+	}
+	response.AvailabilityPercent = 100 * float64(healthy) / float64(samples)
+	return response, nil
+}
+
+// GetUnitAvailability returns a unit availability over the past window.
+func (h *HealthHistory) GetUnitAvailability(unitName string, window time.Duration) (availabilityResponse, error) {
+	h.RLock()
+	defer h.RUnlock()
+	return h.availability(h.Units, unitName, window)
+}
+
+// GetNodeAvailability returns a node availability over the past window.
+func (h *HealthHistory) GetNodeAvailability(nodeIP string, window time.Duration) (availabilityResponse, error) {
+	h.RLock()
+	defer h.RUnlock()
+	return h.availability(h.Nodes, nodeIP, window)
+}
+
+// GetAvailability returns availability of all units and nodes with health samples in the past window.
+func (h *HealthHistory) GetAvailability(window time.Duration) availabilityReportResponse {
+	h.RLock()
+	defer h.RUnlock()
+	var response availabilityReportResponse
+	for name := range h.Units {
+		if a, err := h.availability(h.Units, name, window); err == nil {
+			response.Units = append(response.Units, a)
+		}
+	}
+	for ip := range h.Nodes {
+		if a, err := h.availability(h.Nodes, ip, window); err == nil {
+			response.Nodes = append(response.Nodes, a)
+		}
+	}
+	return response
+}
+
+// parseWindow parses a time window. In addition to time.ParseDuration formats it accepts days and weeks,
+// e.g. 7d or 4w.
+func parseWindow(window string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if strings.HasSuffix(window, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(window, suffix))
+			if err != nil || n <= 0 {
+				return 0, invalidArgumentError("incorrect window %s", window)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, invalidArgumentError("incorrect window %s: %s", window, err)
+	}
+	if d <= 0 {
+		return 0, invalidArgumentError("incorrect window %s", window)
+	}
+	return d, nil
+}
@@ -57,6 +57,9 @@ type DCOSHelper interface {
 	//// GetAgentsFromMaster will lookup agents in DC/OS cluster.
 	GetAgentNodes() ([]Node, error)
 
+	// InvalidateNodesCache makes the next GetMasterNodes and GetAgentNodes calls find the nodes again.
+	InvalidateNodesCache()
+
 	// Get timestamp
 	GetTimestamp() time.Time
 }
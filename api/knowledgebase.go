@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// KnowledgeBaseEntry is a remediation in a knowledge base file. An entry matches an unhealthy unit if the unit name
+// matches any of the unit patterns, e.g. dcos-exhibitor.service or dcos-*.service, and the unit output matches the
+// output regular expression. An entry without units matches any unit, an entry without output matches any output.
+type KnowledgeBaseEntry struct {
+	Units  []string `json:"units,omitempty"`
+	Output string   `json:"output,omitempty"`
+	Help   string   `json:"help"`
+	Links  []string `json:"links,omitempty"`
+}
+
+type knowledgeBaseFile struct {
+	Entries []KnowledgeBaseEntry `json:"entries"`
+}
+
+type knowledgeBaseEntry struct {
+	KnowledgeBaseEntry
+	output *regexp.Regexp
+}
+
+// KnowledgeBase fills in the help of unhealthy units with the remediation maintained in a knowledge base file. The
+// file is read again when it changes, so the entries can be updated without restarting 3dt.
+type KnowledgeBase struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	entries []knowledgeBaseEntry
+
+	// lastError is logged once, not on every unit.
+	lastError string
+}
+
+// Init loads a knowledge base file. An empty path disables the knowledge base.
+func (kb *KnowledgeBase) Init(config *Config) error {
+	kb.Lock()
+	defer kb.Unlock()
+	kb.path = config.FlagKnowledgeBaseFile
+	if kb.path == "" {
+		return nil
+	}
+	if err := kb.load(); err != nil {
+		kb.lastError = err.Error()
+		return err
+	}
+	return nil
+}
+
+// load reads the knowledge base file if it was modified since the last read. The entries read before are kept if
+// the file cannot be read. The caller must hold the lock.
+func (kb *KnowledgeBase) load() error {
+	info, err := os.Stat(kb.path)
+	if err != nil {
+		return err
+	}
+	if kb.modTime.Equal(info.ModTime()) {
+		return nil
+	}
+	// an invalid file is not read again until it changes.
+	kb.modTime = info.ModTime()
+
+	content, err := ioutil.ReadFile(kb.path)
+	if err != nil {
+		return err
+	}
+	var kbf knowledgeBaseFile
+	if err := json.Unmarshal(content, &kbf); err != nil {
+		return fmt.Errorf("could not parse knowledge base %s: %s", kb.path, err)
+	}
+
+	var entries []knowledgeBaseEntry
+	for i, e := range kbf.Entries {
+		entry, err := newKnowledgeBaseEntry(e)
+		if err != nil {
+			return fmt.Errorf("entry %d in knowledge base %s: %s", i, kb.path, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	logrus.Infof("Loaded %d entries from knowledge base %s", len(entries), kb.path)
+	kb.entries = entries
+	return nil
+}
+
+func newKnowledgeBaseEntry(e KnowledgeBaseEntry) (knowledgeBaseEntry, error) {
+	entry := knowledgeBaseEntry{KnowledgeBaseEntry: e}
+	if e.Help == "" {
+		return entry, errors.New("help must be set")
+	}
+	if len(e.Units) == 0 && e.Output == "" {
+		return entry, errors.New("units or output must be set")
+	}
+	for _, pattern := range e.Units {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return entry, fmt.Errorf("incorrect unit pattern %s: %s", pattern, err)
+		}
+	}
+	if e.Output != "" {
+		output, err := regexp.Compile(e.Output)
+		if err != nil {
+			return entry, fmt.Errorf("incorrect output expression %s: %s", e.Output, err)
+		}
+		entry.output = output
+	}
+	return entry, nil
+}
+
+func (e knowledgeBaseEntry) matches(unitName, output string) bool {
+	if e.output != nil && !e.output.MatchString(output) {
+		return false
+	}
+	if len(e.Units) == 0 {
+		return true
+	}
+	for _, pattern := range e.Units {
+		if ok, _ := path.Match(pattern, unitName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Help returns the remediation of every entry matching an unhealthy unit, followed by the entry links. A healthy unit
+// or a nil knowledge base has no help.
+func (kb *KnowledgeBase) Help(unitName string, health int, output string) string {
+	if kb == nil || health == 0 {
+		return ""
+	}
+	kb.Lock()
+	defer kb.Unlock()
+	if kb.path == "" {
+		return ""
+	}
+	if err := kb.load(); err != nil {
+		if err.Error() != kb.lastError {
+			logrus.Errorf("Could not load knowledge base: %s", err)
+		}
+		kb.lastError = err.Error()
+	} else {
+		kb.lastError = ""
+	}
+
+	var help []string
+	for _, e := range kb.entries {
+		if e.matches(unitName, output) {
+			help = append(help, e.Help)
+			help = append(help, e.Links...)
+		}
+	}
+	return strings.Join(help, "\n")
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type KnowledgeBaseTestSuit struct {
+	suite.Suite
+	assert *assertPackage.Assertions
+	path   string
+	kb     *KnowledgeBase
+}
+
+func (s *KnowledgeBaseTestSuit) SetupTest() {
+	s.assert = assertPackage.New(s.T())
+	f, err := ioutil.TempFile("", "3dt-knowledge-base")
+	s.assert.NoError(err)
+	f.Close()
+	s.path = f.Name()
+	s.write(`{
+	  "entries": [
+	    {
+	      "units": ["dcos-exhibitor.service"],
+	      "help": "Check the ZooKeeper quorum.",
+	      "links": ["https://docs.mesosphere.com/exhibitor"]
+	    },
+	    {
+	      "output": "ExecMainStatus return failed",
+	      "help": "The unit process exited, see the unit journal."
+	    },
+	    {
+	      "units": ["dcos-mesos-*.service"],
+	      "output": "(?i)disk full",
+	      "help": "Free up the disk."
+	    }
+	  ]
+	}`, time.Now().Add(-time.Minute))
+
+	s.kb = &KnowledgeBase{}
+	cfg := testCfg
+	cfg.FlagKnowledgeBaseFile = s.path
+	s.assert.NoError(s.kb.Init(&cfg))
+}
+
+func (s *KnowledgeBaseTestSuit) TearDownTest() {
+	os.Remove(s.path)
+}
+
+// write replaces the knowledge base file with a modification time, the file is read again if the time changes.
+func (s *KnowledgeBaseTestSuit) write(content string, modTime time.Time) {
+	s.assert.NoError(ioutil.WriteFile(s.path, []byte(content), 0644))
+	s.assert.NoError(os.Chtimes(s.path, modTime, modTime))
+}
+
+func (s *KnowledgeBaseTestSuit) TestHelp() {
+	s.assert.Equal("Check the ZooKeeper quorum.\nhttps://docs.mesosphere.com/exhibitor",
+		s.kb.Help("dcos-exhibitor.service", 1, ""))
+	s.assert.Equal("Check the ZooKeeper quorum.\nhttps://docs.mesosphere.com/exhibitor\n"+
+		"The unit process exited, see the unit journal.",
+		s.kb.Help("dcos-exhibitor.service", 1, "ExecMainStatus return failed"))
+	s.assert.Equal("Free up the disk.", s.kb.Help("dcos-mesos-slave.service", 1, "Disk full"))
+
+	// a unit or an output does not match.
+	s.assert.Empty(s.kb.Help("dcos-mesos-slave.service", 1, "timeout"))
+	s.assert.Empty(s.kb.Help("dcos-marathon.service", 1, "Disk full"))
+
+	// a healthy unit needs no help.
+	s.assert.Empty(s.kb.Help("dcos-exhibitor.service", 0, ""))
+
+	// a knowledge base is optional.
+	var kb *KnowledgeBase
+	s.assert.Empty(kb.Help("dcos-exhibitor.service", 1, ""))
+	s.assert.Empty((&KnowledgeBase{}).Help("dcos-exhibitor.service", 1, ""))
+}
+
+func (s *KnowledgeBaseTestSuit) TestReload() {
+	s.write(`{"entries": [{"units": ["dcos-marathon.service"], "help": "Restart Marathon."}]}`, time.Now())
+	s.assert.Equal("Restart Marathon.", s.kb.Help("dcos-marathon.service", 1, ""))
+	s.assert.Empty(s.kb.Help("dcos-exhibitor.service", 1, ""))
+
+	// an invalid file keeps the entries read before.
+	s.write(`{"entries": [{"units": ["dcos-marathon.service"]}]}`, time.Now().Add(time.Minute))
+	s.assert.Equal("Restart Marathon.", s.kb.Help("dcos-marathon.service", 1, ""))
+}
+
+func (s *KnowledgeBaseTestSuit) TestInvalidEntries() {
+	for _, content := range []string{
+		`{"entries": [{"units": ["dcos-marathon.service"]}]}`,
+		`{"entries": [{"help": "Restart the unit."}]}`,
+		`{"entries": [{"units": ["dcos-[.service"], "help": "Restart the unit."}]}`,
+		`{"entries": [{"output": "(", "help": "Restart the unit."}]}`,
+		`{"entries": {}}`,
+	} {
+		s.write(content, time.Now())
+		cfg := testCfg
+		cfg.FlagKnowledgeBaseFile = s.path
+		s.assert.Error((&KnowledgeBase{}).Init(&cfg), content)
+	}
+}
+
+func (s *KnowledgeBaseTestSuit) TestPullHelp() {
+	dt := Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}, DtKnowledgeBase: s.kb}
+	s.write(`{"entries": [{"units": ["dcos-agent.service"], "help": "Check the agent work dir."}]}`, time.Now())
+	runPull(dt)
+	defer globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{})
+
+	nodeHint := "Node available at `dcos node ssh -mesos-id agent-123`. Try, `journalctl -xv` to diagnose further."
+	unit, err := globalMonitoringResponse.GetNodeUnitByNodeIDUnitID("127.0.0.2", "dcos-agent.service")
+	s.assert.NoError(err)
+	s.assert.Equal("Check the agent work dir.\n"+nodeHint, unit.Help)
+
+	node, err := globalMonitoringResponse.GetSpecificNodeForUnit("dcos-agent.service", "127.0.0.2")
+	s.assert.NoError(err)
+	s.assert.Equal("Check the agent work dir.\n"+nodeHint, node.Help)
+
+	v2, err := globalMonitoringResponse.GetUnitOnNodeV2("agent-123", "dcos-agent.service")
+	s.assert.NoError(err)
+	s.assert.Equal("Check the agent work dir.\n"+nodeHint, v2.Help)
+
+	// a healthy unit has the node hint only.
+	unit, err = globalMonitoringResponse.GetNodeUnitByNodeIDUnitID("127.0.0.2", "dcos-setup.service")
+	s.assert.NoError(err)
+	s.assert.Equal(nodeHint, unit.Help)
+}
+
+func TestKnowledgeBaseTestSuit(t *testing.T) {
+	suite.Run(t, new(KnowledgeBaseTestSuit))
+}
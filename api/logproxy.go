@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// logProxyFlushInterval is how often a proxied log is flushed to the client.
+const logProxyFlushInterval = 100 * time.Millisecond
+
+// getNode returns a node of the health report by IP address, Mesos ID or hostname.
+func (mr *monitoringResponse) getNode(nodeID string) (Node, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+	return mr.findNode(nodeID)
+}
+
+// nodeTransport makes the proxied requests to 3dt on a node with the puller's requester and its CA. The
+// schemes allowed by the TLS mode are tried in order until the node is reached, like the puller does.
+type nodeTransport struct {
+	config *Config
+	node   Node
+}
+
+func (t nodeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	_, err := doNodeRequest(t.config, t.node, func(baseURL string) error {
+		target, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		outReq := req.Clone(req.Context())
+		outReq.URL.Scheme = target.Scheme
+		outReq.URL.Host = target.Host
+		outReq.Host = target.Host
+		// the requester is an HTTP client, a client request must not have a request URI.
+		outReq.RequestURI = ""
+
+		// a log is streamed as long as the client reads it, the request is canceled with the client request.
+		resp, err = Requester.Do(outReq, 0)
+		return err
+	})
+	return resp, err
+}
+
+// newNodeProxy returns a reverse proxy to a path of 3dt on a node.
+func newNodeProxy(config *Config, node Node, path string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Path = path
+			req.URL.RawPath = ""
+		},
+		Transport:     nodeTransport{config: config, node: node},
+		FlushInterval: logProxyFlushInterval,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, unavailableError("Could not reach node %s: %s", node.IP, err))
+		},
+	}
+}
+
+// serveNodeProxy resolves a node of the health report and proxies the request to a path of 3dt on the node.
+func serveNodeProxy(w http.ResponseWriter, r *http.Request, dt Dt, path string,
+	modify func(*http.Response, Node) error) {
+	node, err := globalMonitoringResponse.getNode(mux.Vars(r)["nodeid"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	proxy := newNodeProxy(dt.Cfg, node, path)
+	if modify != nil {
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			return modify(resp, node)
+		}
+	}
+
+	// the node sets the content type.
+	w.Header().Del("Content-type")
+	proxy.ServeHTTP(w, r)
+}
+
+// /system/health/v1/nodes/<nodeid>/logs, list the logs available on a node. The logs served by 3dt on the node are
+// listed with the master routes.
+func nodeLogsListHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	// the list is rewritten, it must not be compressed.
+	r.Header.Del("Accept-Encoding")
+	serveNodeProxy(w, r, dt, BaseRoute+"/logs", func(resp *http.Response, node Node) error {
+		if resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		port, err := getNodePort(dt.Cfg, node)
+		if err != nil {
+			return err
+		}
+
+		var endpoints map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		nodeLogs := fmt.Sprintf(":%d%s/logs/", port, BaseRoute)
+		masterLogs := fmt.Sprintf("%s/nodes/%s/logs/", BaseRoute, url.PathEscape(mux.Vars(r)["nodeid"]))
+		for name, endpoint := range endpoints {
+			if strings.HasPrefix(endpoint, nodeLogs) {
+				endpoints[name] = masterLogs + strings.TrimPrefix(endpoint, nodeLogs)
+			}
+		}
+
+		body, err := json.Marshal(endpoints)
+		if err != nil {
+			return err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		return nil
+	})
+}
+
+// /system/health/v1/nodes/<nodeid>/logs/<provider>/<entity>, stream a log from a node.
+func nodeLogHandler(w http.ResponseWriter, r *http.Request, dt Dt) {
+	vars := mux.Vars(r)
+	serveNodeProxy(w, r, dt, fmt.Sprintf("%s/logs/%s/%s", BaseRoute, vars["provider"], vars["entity"]), nil)
+}
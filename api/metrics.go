@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix is a prefix of all metric names.
+const metricsPrefix = "dcos_3dt_"
+
+// metricsContentType is a content type of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4"
+
+// metricFamily is a gauge with samples in the Prometheus text exposition format.
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels []metricLabel
+	value  float64
+}
+
+type metricLabel struct {
+	name, value string
+}
+
+func newMetricFamily(name, help string) *metricFamily {
+	return &metricFamily{name: metricsPrefix + name, help: help}
+}
+
+// add adds a sample, labels are given as name and value pairs.
+func (f *metricFamily) add(value float64, labels ...string) {
+	sample := metricSample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.labels = append(sample.labels, metricLabel{name: labels[i], value: labels[i+1]})
+	}
+	f.samples = append(f.samples, sample)
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics writes metric families in the Prometheus text exposition format. Families without samples are skipped.
+func writeMetrics(w io.Writer, families []*metricFamily) error {
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name); err != nil {
+			return err
+		}
+		for _, sample := range f.samples {
+			var labels []string
+			for _, l := range sample.labels {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, l.name, metricLabelEscaper.Replace(l.value)))
+			}
+			line := f.name
+			if len(labels) > 0 {
+				line += "{" + strings.Join(labels, ",") + "}"
+			}
+			if _, err := fmt.Fprintf(w, "%s %s\n", line, strconv.FormatFloat(sample.value, 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// localMetrics returns the health of the units and the system metrics of the local node.
+func localMetrics(health UnitsHealthResponseJSONStruct) []*metricFamily {
+	unitHealth := newMetricFamily("unit_health", "Health of a systemd unit on the local node, 0 is healthy.")
+	units := health.Array
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].UnitID < units[j].UnitID
+	})
+	for _, u := range units {
+		unitHealth.add(float64(u.UnitHealth), "unit", u.UnitID, "name", u.PrettyName)
+	}
+
+	system := health.System
+	memoryTotal := newMetricFamily("memory_total_bytes", "Total memory of the local node.")
+	memoryAvailable := newMetricFamily("memory_available_bytes", "Memory available for programs on the local node.")
+	memoryUsed := newMetricFamily("memory_used_percent", "Memory used on the local node in percent.")
+	if system.Memory.Total > 0 {
+		memoryTotal.add(float64(system.Memory.Total))
+		memoryAvailable.add(float64(system.Memory.Available))
+		memoryUsed.add(system.Memory.UsedPercent)
+	}
+
+	load := newMetricFamily("load_average", "System load average of the local node.")
+	load.add(system.LoadAvarage.Load1, "period", "1m")
+	load.add(system.LoadAvarage.Load5, "period", "5m")
+	load.add(system.LoadAvarage.Load15, "period", "15m")
+
+	diskTotal := newMetricFamily("disk_total_bytes", "Size of a partition on the local node.")
+	diskUsed := newMetricFamily("disk_used_bytes", "Used space of a partition on the local node.")
+	diskUsedPercent := newMetricFamily("disk_used_percent", "Used space of a partition on the local node in percent.")
+	paths := make(map[string]bool)
+	for _, usage := range system.DiskUsage {
+		// a partition could be mounted more than once, every path is a single series.
+		if paths[usage.Path] {
+			continue
+		}
+		paths[usage.Path] = true
+		diskTotal.add(float64(usage.Total), "path", usage.Path)
+		diskUsed.add(float64(usage.Used), "path", usage.Path)
+		diskUsedPercent.add(usage.UsedPercent, "path", usage.Path)
+	}
+
+	return []*metricFamily{unitHealth, memoryTotal, memoryAvailable, memoryUsed, load, diskTotal, diskUsed,
+		diskUsedPercent}
+}
+
+// clusterMetrics returns the health of the units on every node, the node health and the age of the health report.
+func (mr *monitoringResponse) clusterMetrics(now time.Time) []*metricFamily {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	unitNames := make([]string, 0, len(mr.Units))
+	for name := range mr.Units {
+		unitNames = append(unitNames, name)
+	}
+	sort.Strings(unitNames)
+
+	clusterUnitHealth := newMetricFamily("cluster_unit_health", "Aggregated health of a unit in the cluster.")
+	unitNodeHealth := newMetricFamily("cluster_unit_node_health", "Health of a unit on a cluster node.")
+	for _, name := range unitNames {
+		u := mr.Units[name]
+		clusterUnitHealth.add(float64(u.Health), "unit", name)
+
+		nodes := make([]unitOnNodeV2, 0, len(u.Nodes))
+		for _, node := range u.Nodes {
+			nodes = append(nodes, mr.unitOnNode(u, node))
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].IP < nodes[j].IP
+		})
+		for _, node := range nodes {
+			unitNodeHealth.add(float64(node.Health), "unit", name, "node", node.IP, "role", node.Role, "host",
+				node.Hostname)
+		}
+	}
+
+	ips := make([]string, 0, len(mr.Nodes))
+	for ip := range mr.Nodes {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	nodeHealth := newMetricFamily("cluster_node_health", "Health of a cluster node, the worst health of its units.")
+	for _, ip := range ips {
+		node := mr.Nodes[ip]
+		nodeHealth.add(float64(node.Health), "node", ip, "role", node.Role, "host", node.Host)
+	}
+
+	reportAge := newMetricFamily("cluster_report_age_seconds", "Seconds since the cluster health report was updated.")
+	if !mr.UpdatedTime.IsZero() {
+		reportAge.add(now.Sub(mr.UpdatedTime).Seconds())
+	}
+
+	return []*metricFamily{clusterUnitHealth, unitNodeHealth, nodeHealth, reportAge}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// missingNodesUnitName is a synthetic cluster unit, unhealthy if previously seen nodes are not reported anymore.
+const missingNodesUnitName = "dcos-missing-nodes"
+
+// NodeTracker remembers every node seen in the cluster health report. A node which is not reported anymore is marked
+// missing until an operator acknowledges its removal. Known nodes are persisted to disk, so the missing nodes are
+// detected after a restart. The file is written only when a node is added, removed, changed or goes missing, the
+// last seen time alone is not persisted.
+type NodeTracker struct {
+	sync.RWMutex
+	path  string
+	nodes map[string]*knownNode
+
+	// changed is set if the known nodes changed since they were saved.
+	changed bool
+}
+
+// knownNode is a node seen in the cluster health report.
+type knownNode struct {
+	IP        string    `json:"ip"`
+	Role      string    `json:"role"`
+	Hostname  string    `json:"hostname,omitempty"`
+	MesosID   string    `json:"mesos_id,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Missing   bool      `json:"missing"`
+}
+
+// known nodes response
+type knownNodesResponseJSONStruct struct {
+	Array []knownNode `json:"nodes"`
+}
+
+// Init loads the known nodes from a file.
+func (t *NodeTracker) Init(config *Config) error {
+	t.Lock()
+	defer t.Unlock()
+	t.path = config.FlagKnownNodesFile
+	t.nodes = make(map[string]*knownNode)
+	if t.path == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logrus.Infof("%s not found, no nodes are known yet", t.path)
+			return nil
+		}
+		return err
+	}
+
+	var nodes []knownNode
+	if err := json.Unmarshal(content, &nodes); err != nil {
+		return err
+	}
+	for i := range nodes {
+		t.nodes[nodes[i].IP] = &nodes[i]
+	}
+	return nil
+}
+
+// save writes the known nodes to a file if they changed since the last save.
+func (t *NodeTracker) save() error {
+	t.Lock()
+	defer t.Unlock()
+	if t.path == "" || !t.changed {
+		return nil
+	}
+
+	content, err := json.Marshal(t.knownNodes())
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(t.path, content); err != nil {
+		return err
+	}
+	t.changed = false
+	return nil
+}
+
+// update marks the nodes in globalMonitoringResponse seen, the rest of the known nodes missing. The missing nodes
+// are reported by a synthetic unit in globalMonitoringResponse.
+func (t *NodeTracker) update() {
+	if t == nil {
+		return
+	}
+	defer func() {
+		if err := t.save(); err != nil {
+			logrus.Errorf("Could not save known nodes to %s: %s", t.path, err)
+		}
+	}()
+
+	globalMonitoringResponse.Lock()
+	defer globalMonitoringResponse.Unlock()
+
+	// the nodes could not be discovered, do not consider all of them missing.
+	if len(globalMonitoringResponse.Nodes) == 0 {
+		return
+	}
+
+	t.Lock()
+	now := time.Now()
+	for ip, node := range globalMonitoringResponse.Nodes {
+		known, ok := t.nodes[ip]
+		if !ok {
+			known = &knownNode{IP: ip, FirstSeen: now}
+			t.nodes[ip] = known
+		}
+		if !ok || known.Missing || known.Role != node.Role || known.Hostname != node.Host || known.MesosID != node.MesosID {
+			t.changed = true
+		}
+		known.Role = node.Role
+		known.Hostname = node.Host
+		known.MesosID = node.MesosID
+		known.LastSeen = now
+		known.Missing = false
+	}
+
+	u := unit{
+		UnitName:   missingNodesUnitName,
+		Title:      "All known nodes are reported",
+		PrettyName: "Missing Nodes",
+		Timestamp:  now,
+	}
+	var missing []string
+	for ip, known := range t.nodes {
+		if _, ok := globalMonitoringResponse.Nodes[ip]; ok {
+			continue
+		}
+		if !known.Missing {
+			logrus.Warningf("Node %s is missing, last seen at %s", ip, known.LastSeen)
+			t.changed = true
+		}
+		known.Missing = true
+		u.Nodes = append(u.Nodes, Node{IP: ip, Role: known.Role, Host: known.Hostname, MesosID: known.MesosID, Health: 1})
+		missing = append(missing, fmt.Sprintf("%s (last seen %s)", ip, known.LastSeen.Format(time.RFC3339)))
+	}
+	t.Unlock()
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		u.Health = 1
+		u.Title = fmt.Sprintf("%d nodes are missing: %s. Forget the removed nodes with DELETE %s/nodes/<ip>",
+			len(missing), strings.Join(missing, ", "), BaseRoute)
+	}
+
+	// the units map could be shared with the health history, replace it instead of modifying.
+	units := make(map[string]unit)
+	for name, existing := range globalMonitoringResponse.Units {
+		units[name] = existing
+	}
+	units[u.UnitName] = u
+	globalMonitoringResponse.Units = units
+	globalMonitoringResponse.publish()
+}
+
+// GetKnownNodes returns all known nodes sorted by IP address.
+func (t *NodeTracker) GetKnownNodes() knownNodesResponseJSONStruct {
+	t.RLock()
+	defer t.RUnlock()
+	return knownNodesResponseJSONStruct{Array: t.knownNodes()}
+}
+
+// knownNodes returns all known nodes sorted by IP address. The caller must hold the lock.
+func (t *NodeTracker) knownNodes() []knownNode {
+	nodes := []knownNode{}
+	for _, known := range t.nodes {
+		nodes = append(nodes, *known)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].IP < nodes[j].IP
+	})
+	return nodes
+}
+
+// getKnownNode returns a known node by IP address.
+func (t *NodeTracker) getKnownNode(ip string) (knownNode, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	known, ok := t.nodes[ip]
+	if !ok {
+		return knownNode{}, false
+	}
+	return *known, true
+}
+
+// Forget removes a node from the known nodes, e.g. a node was decommissioned. The missing nodes unit is updated.
+func (t *NodeTracker) Forget(ip string) error {
+	t.Lock()
+	if _, ok := t.nodes[ip]; !ok {
+		t.Unlock()
+		return notFoundError("Node %s not found", ip)
+	}
+	delete(t.nodes, ip)
+	t.changed = true
+	t.Unlock()
+
+	logrus.Infof("Node %s removed from the known nodes", ip)
+	t.update()
+	return nil
+}
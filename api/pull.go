@@ -1,13 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -31,8 +34,9 @@ var globalMonitoringResponse monitoringResponse
 
 // find masters via dns. Used to find master nodes from agents.
 type findMastersInExhibitor struct {
-	url  string
-	next nodeFinder
+	url     string
+	timeout time.Duration
+	next    nodeFinder
 
 	// getFn takes url and timeout and returns a read body, HTTP status code and error.
 	getFn func(string, time.Duration) ([]byte, int, error)
@@ -42,7 +46,10 @@ func (f *findMastersInExhibitor) findMesosMasters() (nodes []Node, err error) {
 	if f.getFn == nil {
 		return nodes, errors.New("Could not initialize HTTP GET function. Make sure you set getFn in the constructor.")
 	}
-	timeout := time.Duration(time.Second * 11)
+	timeout := f.timeout
+	if timeout == 0 {
+		timeout = time.Duration(time.Second * 11)
+	}
 	body, statusCode, err := f.getFn(f.url, timeout)
 	if err != nil {
 		return nodes, err
@@ -92,19 +99,57 @@ func (n NodesNotFoundError) Error() string {
 	return n.msg
 }
 
+// node returns a cluster node with the agent mesos ID and metadata.
+func (a mesosAgent) node() Node {
+	info := &mesosAgentInfo{
+		Active:     a.Active,
+		Resources:  a.Resources,
+		Attributes: make(map[string]string),
+	}
+	if a.RegisteredTime > 0 {
+		sec, frac := math.Modf(a.RegisteredTime)
+		info.RegisteredTime = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	}
+	for name, value := range a.Attributes {
+		if f, ok := value.(float64); ok {
+			info.Attributes[name] = strconv.FormatFloat(f, 'f', -1, 64)
+			continue
+		}
+		info.Attributes[name] = fmt.Sprint(value)
+	}
+
+	role := AgentRole
+	// if a node has "attributes": {"public_ip": "true"} we consider it to be a public agent
+	if info.Attributes["public_ip"] == "true" {
+		role = AgentPublicRole
+	}
+	return Node{
+		Role:    role,
+		IP:      a.Hostname,
+		MesosID: a.ID,
+		Mesos:   info,
+	}
+}
+
 // find agents in history service
 type findAgentsInHistoryService struct {
+	basePath string
 	pastTime string
 	next     nodeFinder
 }
 
 func (f *findAgentsInHistoryService) getMesosAgents() (nodes []Node, err error) {
-	basePath := "/var/lib/dcos/dcos-history" + f.pastTime
+	basePath := f.basePath
+	if basePath == "" {
+		basePath = "/var/lib/dcos/dcos-history"
+	}
+	basePath += f.pastTime
 	files, err := ioutil.ReadDir(basePath)
 	if err != nil {
 		return nodes, err
 	}
-	nodeCount := make(map[string]int)
+	// history files are sorted by time, the most recent agent state is kept.
+	agentsByHostname := make(map[string]mesosAgent)
 	for _, historyFile := range files {
 		filePath := filepath.Join(basePath, historyFile.Name())
 		agents, err := ioutil.ReadFile(filePath)
@@ -126,26 +171,22 @@ func (f *findAgentsInHistoryService) getMesosAgents() (nodes []Node, err error)
 		}
 
 		for _, agent := range sr.Agents {
-			if _, ok := nodeCount[agent.Hostname]; ok {
-				nodeCount[agent.Hostname]++
-			} else {
-				nodeCount[agent.Hostname] = 1
-			}
+			agentsByHostname[agent.Hostname] = agent
 		}
 
 	}
-	if len(nodeCount) == 0 {
+	if len(agentsByHostname) == 0 {
 		return nodes, NodesNotFoundError{
 			msg: fmt.Sprintf("Agent nodes were not found in history service for the past %s", f.pastTime),
 		}
 	}
 
-	for ip := range nodeCount {
-		nodes = append(nodes, Node{
-			Role: AgentRole,
-			IP:   ip,
-		})
+	for _, agent := range agentsByHostname {
+		nodes = append(nodes, agent.node())
 	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].IP < nodes[j].IP
+	})
 	return nodes, nil
 }
 
@@ -170,12 +211,21 @@ type findNodesInDNS struct {
 	role      string
 	next      nodeFinder
 
+	// port is a mesos master port, timeout is used to resolve the dns record and to get agents from mesos.
+	port    int
+	timeout time.Duration
+
 	// getFn takes url and timeout and returns a read body, HTTP status code and error.
 	getFn func(string, time.Duration) ([]byte, int, error)
 }
 
 func (f *findNodesInDNS) resolveDomain() (ips []string, err error) {
-	return net.LookupHost(f.dnsRecord)
+	if f.timeout == 0 {
+		return net.LookupHost(f.dnsRecord)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	return net.DefaultResolver.LookupHost(ctx, f.dnsRecord)
 }
 
 func (f *findNodesInDNS) getMesosMasters() (nodes []Node, err error) {
@@ -208,12 +258,19 @@ func (f *findNodesInDNS) getMesosAgents() (nodes []Node, err error) {
 		return nodes, errors.New("Could not resolve " + f.dnsRecord)
 	}
 
-	url, err := useTLSScheme(fmt.Sprintf("http://%s:5050/slaves", leaderIps[0]), f.forceTLS)
+	port := f.port
+	if port == 0 {
+		port = 5050
+	}
+	url, err := useTLSScheme(fmt.Sprintf("http://%s:%d/slaves", leaderIps[0], port), f.forceTLS)
 	if err != nil {
 		return nodes, err
 	}
 
-	timeout := time.Duration(time.Second)
+	timeout := f.timeout
+	if timeout == 0 {
+		timeout = time.Duration(time.Second)
+	}
 	body, statusCode, err := f.getFn(url, timeout)
 	if err != nil {
 		return nodes, err
@@ -228,16 +285,7 @@ func (f *findNodesInDNS) getMesosAgents() (nodes []Node, err error) {
 	}
 
 	for _, agent := range sr.Agents {
-		role := AgentRole
-
-		// if a node has "attributes": {"public_ip": "true"} we consider it to be a public agent
-		if agent.Attributes.PublicIP == "true" {
-			role = AgentPublicRole
-		}
-		nodes = append(nodes, Node{
-			Role: role,
-			IP:   agent.Hostname,
-		})
+		nodes = append(nodes, agent.node())
 	}
 	return nodes, nil
 }
@@ -271,6 +319,7 @@ func (mr *monitoringResponse) updateMonitoringResponse(r monitoringResponse) {
 	mr.Nodes = r.Nodes
 	mr.Units = r.Units
 	mr.UpdatedTime = r.UpdatedTime
+	mr.publish()
 }
 
 // Get all units available in globalMonitoringResponse
@@ -282,10 +331,11 @@ func (mr *monitoringResponse) GetAllUnits() unitsResponseJSONStruct {
 			var r []unitResponseFieldsStruct
 			for _, unit := range mr.Units {
 				r = append(r, unitResponseFieldsStruct{
-					unit.UnitName,
-					unit.PrettyName,
-					unit.Health,
-					unit.Title,
+					UnitID:      unit.UnitName,
+					PrettyName:  unit.PrettyName,
+					UnitHealth:  unit.Health,
+					UnitTitle:   unit.Title,
+					Aggregation: unit.Aggregation,
 				})
 			}
 			return r
@@ -298,14 +348,15 @@ func (mr *monitoringResponse) GetUnit(unitName string) (unitResponseFieldsStruct
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Units[unitName]; !ok {
-		return unitResponseFieldsStruct{}, fmt.Errorf("Unit %s not found", unitName)
+		return unitResponseFieldsStruct{}, notFoundError("Unit %s not found", unitName)
 	}
 
 	return unitResponseFieldsStruct{
-		mr.Units[unitName].UnitName,
-		mr.Units[unitName].PrettyName,
-		mr.Units[unitName].Health,
-		mr.Units[unitName].Title,
+		UnitID:      mr.Units[unitName].UnitName,
+		PrettyName:  mr.Units[unitName].PrettyName,
+		UnitHealth:  mr.Units[unitName].Health,
+		UnitTitle:   mr.Units[unitName].Title,
+		Aggregation: mr.Units[unitName].Aggregation,
 	}, nil
 
 }
@@ -315,16 +366,16 @@ func (mr *monitoringResponse) GetNodesForUnit(unitName string) (nodesResponseJSO
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Units[unitName]; !ok {
-		return nodesResponseJSONStruct{}, fmt.Errorf("Unit %s not found", unitName)
+		return nodesResponseJSONStruct{}, notFoundError("Unit %s not found", unitName)
 	}
 	return nodesResponseJSONStruct{
 		Array: func() []*nodeResponseFieldsStruct {
 			var r []*nodeResponseFieldsStruct
 			for _, node := range mr.Units[unitName].Nodes {
 				r = append(r, &nodeResponseFieldsStruct{
-					node.IP,
-					node.Health,
-					node.Role,
+					HostIP:     node.IP,
+					NodeHealth: node.Health,
+					NodeRole:   node.Role,
 				})
 			}
 			return r
@@ -332,26 +383,40 @@ func (mr *monitoringResponse) GetNodesForUnit(unitName string) (nodesResponseJSO
 	}, nil
 }
 
+// nodeHelp returns a hint how to diagnose a unit on a node.
+func nodeHelp(node Node) string {
+	return fmt.Sprintf("Node available at `dcos node ssh -mesos-id %s`. Try, `journalctl -xv` to diagnose further.", node.MesosID)
+}
+
+// unitHelp returns the remediation of a unit found in the knowledge base followed by a hint how to diagnose the unit
+// on a node.
+func unitHelp(node Node, unitName string) string {
+	if help := node.Help[unitName]; help != "" {
+		return help + "\n" + nodeHelp(node)
+	}
+	return nodeHelp(node)
+}
+
 func (mr *monitoringResponse) GetSpecificNodeForUnit(unitName string, nodeIP string) (nodeResponseFieldsWithErrorStruct, error) {
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Units[unitName]; !ok {
-		return nodeResponseFieldsWithErrorStruct{}, fmt.Errorf("Unit %s not found", unitName)
+		return nodeResponseFieldsWithErrorStruct{}, notFoundError("Unit %s not found", unitName)
 	}
 
 	for _, node := range mr.Units[unitName].Nodes {
 		if node.IP == nodeIP {
-			helpField := fmt.Sprintf("Node available at `dcos node ssh -mesos-id %s`. Try, `journalctl -xv` to diagnose further.", node.MesosID)
+			helpField := unitHelp(node, unitName)
 			return nodeResponseFieldsWithErrorStruct{
-				node.IP,
-				node.Health,
-				node.Role,
-				node.Output[unitName],
-				helpField,
+				HostIP:     node.IP,
+				NodeHealth: node.Health,
+				NodeRole:   node.Role,
+				UnitOutput: node.Output[unitName],
+				Help:       helpField,
 			}, nil
 		}
 	}
-	return nodeResponseFieldsWithErrorStruct{}, fmt.Errorf("Node %s not found", nodeIP)
+	return nodeResponseFieldsWithErrorStruct{}, notFoundError("Node %s not found", nodeIP)
 }
 
 func (mr *monitoringResponse) GetNodes() nodesResponseJSONStruct {
@@ -362,9 +427,9 @@ func (mr *monitoringResponse) GetNodes() nodesResponseJSONStruct {
 			var nodes []*nodeResponseFieldsStruct
 			for _, node := range mr.Nodes {
 				nodes = append(nodes, &nodeResponseFieldsStruct{
-					node.IP,
-					node.Health,
-					node.Role,
+					HostIP:     node.IP,
+					NodeHealth: node.Health,
+					NodeRole:   node.Role,
 				})
 			}
 			return nodes
@@ -399,12 +464,12 @@ func (mr *monitoringResponse) GetNodeByID(nodeIP string) (nodeResponseFieldsStru
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Nodes[nodeIP]; !ok {
-		return nodeResponseFieldsStruct{}, fmt.Errorf("Node %s not found", nodeIP)
+		return nodeResponseFieldsStruct{}, notFoundError("Node %s not found", nodeIP)
 	}
 	return nodeResponseFieldsStruct{
-		mr.Nodes[nodeIP].IP,
-		mr.Nodes[nodeIP].Health,
-		mr.Nodes[nodeIP].Role,
+		HostIP:     mr.Nodes[nodeIP].IP,
+		NodeHealth: mr.Nodes[nodeIP].Health,
+		NodeRole:   mr.Nodes[nodeIP].Role,
 	}, nil
 }
 
@@ -412,17 +477,17 @@ func (mr *monitoringResponse) GetNodeUnitsID(nodeIP string) (unitsResponseJSONSt
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Nodes[nodeIP]; !ok {
-		return unitsResponseJSONStruct{}, fmt.Errorf("Node %s not found", nodeIP)
+		return unitsResponseJSONStruct{}, notFoundError("Node %s not found", nodeIP)
 	}
 	return unitsResponseJSONStruct{
 		Array: func(nodeIp string) []unitResponseFieldsStruct {
 			var units []unitResponseFieldsStruct
 			for _, unit := range mr.Nodes[nodeIp].Units {
 				units = append(units, unitResponseFieldsStruct{
-					unit.UnitName,
-					unit.PrettyName,
-					unit.Health,
-					unit.Title,
+					UnitID:     unit.UnitName,
+					PrettyName: unit.PrettyName,
+					UnitHealth: unit.Health,
+					UnitTitle:  unit.Title,
 				})
 			}
 			return units
@@ -434,11 +499,11 @@ func (mr *monitoringResponse) GetNodeUnitByNodeIDUnitID(nodeIP string, unitID st
 	mr.RLock()
 	defer mr.RUnlock()
 	if _, ok := mr.Nodes[nodeIP]; !ok {
-		return healthResponseValues{}, fmt.Errorf("Node %s not found", nodeIP)
+		return healthResponseValues{}, notFoundError("Node %s not found", nodeIP)
 	}
 	for _, unit := range mr.Nodes[nodeIP].Units {
 		if unit.UnitName == unitID {
-			helpField := fmt.Sprintf("Node available at `dcos node ssh -mesos-id %s`. Try, `journalctl -xv` to diagnose further.", mr.Nodes[nodeIP].MesosID)
+			helpField := unitHelp(mr.Nodes[nodeIP], unit.UnitName)
 			return healthResponseValues{
 				UnitID:     unit.UnitName,
 				UnitHealth: unit.Health,
@@ -449,7 +514,133 @@ func (mr *monitoringResponse) GetNodeUnitByNodeIDUnitID(nodeIP string, unitID st
 			}, nil
 		}
 	}
-	return healthResponseValues{}, fmt.Errorf("Unit %s not found", unitID)
+	return healthResponseValues{}, notFoundError("Unit %s not found", unitID)
+}
+
+// GetNodeSystem returns the system metrics reported by a node.
+func (mr *monitoringResponse) GetNodeSystem(nodeIP string) (nodeSystemResponseJSONStruct, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+	node, ok := mr.Nodes[nodeIP]
+	if !ok {
+		return nodeSystemResponseJSONStruct{}, notFoundError("Node %s not found", nodeIP)
+	}
+	if node.System == nil {
+		return nodeSystemResponseJSONStruct{}, notFoundError("Node %s did not report system metrics", nodeIP)
+	}
+	return nodeSystemResponseJSONStruct{
+		HostIP: node.IP,
+		Role:   node.Role,
+		System: *node.System,
+	}, nil
+}
+
+// GetSystemTop returns up to limit nodes with the highest disk usage, memory usage and load average. If role is not
+// empty, only the nodes with the role are considered. A node disk usage is the usage of its fullest partition.
+func (mr *monitoringResponse) GetSystemTop(limit int, role string) systemTopResponseJSONStruct {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	var top systemTopResponseJSONStruct
+	for _, node := range mr.Nodes {
+		if node.System == nil || (role != "" && node.Role != role) {
+			continue
+		}
+
+		disk := nodeUsageResponseFieldsStruct{HostIP: node.IP, Role: node.Role}
+		for _, usage := range node.System.DiskUsage {
+			if usage.UsedPercent >= disk.Value {
+				disk.Value = usage.UsedPercent
+				disk.Path = usage.Path
+			}
+		}
+		top.Disk = append(top.Disk, disk)
+		top.Memory = append(top.Memory, nodeUsageResponseFieldsStruct{
+			HostIP: node.IP,
+			Role:   node.Role,
+			Value:  node.System.Memory.UsedPercent,
+		})
+		top.Load = append(top.Load, nodeUsageResponseFieldsStruct{
+			HostIP: node.IP,
+			Role:   node.Role,
+			Value:  node.System.LoadAvarage.Load1,
+		})
+	}
+
+	top.Disk = topNodeUsage(top.Disk, limit)
+	top.Memory = topNodeUsage(top.Memory, limit)
+	top.Load = topNodeUsage(top.Load, limit)
+	return top
+}
+
+// topNodeUsage sorts nodes by usage value in descending order and returns up to limit nodes.
+func topNodeUsage(nodes []nodeUsageResponseFieldsStruct, limit int) []nodeUsageResponseFieldsStruct {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Value == nodes[j].Value {
+			return nodes[i].HostIP < nodes[j].HostIP
+		}
+		return nodes[i].Value > nodes[j].Value
+	})
+	if len(nodes) > limit {
+		return nodes[:limit]
+	}
+	return nodes
+}
+
+// updateNodes replaces the nodes with fresh host responses and rebuilds the units. The last updated time is not
+// changed, since the rest of the nodes were not pulled.
+func (mr *monitoringResponse) updateNodes(fresh []*httpResponse, policies map[string]AggregationPolicy) {
+	mr.Lock()
+	defer mr.Unlock()
+
+	responses := make(chan *httpResponse, len(mr.Nodes)+len(fresh))
+	replaced := make(map[string]bool)
+	for _, response := range fresh {
+		replaced[response.Node.IP] = true
+		responses <- response
+	}
+	for ip, node := range mr.Nodes {
+		if replaced[ip] {
+			continue
+		}
+		responses <- &httpResponse{
+			Node:  node,
+			Units: node.Units,
+		}
+	}
+	mr.Units, mr.Nodes = aggregateResponses(responses, policies)
+	mr.publish()
+}
+
+// getNodesByIP returns the nodes with given IP addresses. The second return value is false if at least
+// one of the nodes was not found.
+func (mr *monitoringResponse) getNodesByIP(nodeIPs []string) ([]Node, bool) {
+	mr.RLock()
+	defer mr.RUnlock()
+	var nodes []Node
+	for _, ip := range nodeIPs {
+		node, ok := mr.Nodes[ip]
+		if !ok {
+			return nodes, false
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, true
+}
+
+// getUnitNodeIPs returns IP addresses of the nodes a unit is running on.
+func (mr *monitoringResponse) getUnitNodeIPs(unitName string) ([]string, bool) {
+	mr.RLock()
+	defer mr.RUnlock()
+	u, ok := mr.Units[unitName]
+	if !ok {
+		return nil, false
+	}
+	var ips []string
+	for _, node := range u.Nodes {
+		ips = append(ips, node.IP)
+	}
+	return ips, true
 }
 
 func (mr *monitoringResponse) GetLastUpdatedTime() string {
@@ -463,21 +654,13 @@ func (mr *monitoringResponse) GetLastUpdatedTime() string {
 
 // StartPullWithInterval will start to pull a DC/OS cluster health status
 func StartPullWithInterval(dt Dt) {
-	// Start infinite loop
+	// Start infinite loop. On-demand refresh requests are coalesced with the scheduled pulls by dt.PullRefresher.
 	for {
-		runPull(dt)
-	inner:
-		select {
-		case <-dt.RunPullerChan:
-			logrus.Debug("Update cluster health request recevied")
+		<-dt.PullRefresher.refresh(refreshAll, func() {
 			runPull(dt)
-			dt.RunPullerDoneChan <- true
-			goto inner
-
-		case <-time.After(time.Duration(dt.Cfg.FlagPullInterval) * time.Second):
-			logrus.Debugf("Update cluster health after %d interval", dt.Cfg.FlagPullInterval)
-		}
-
+		})
+		time.Sleep(time.Duration(dt.Cfg.FlagPullInterval) * time.Second)
+		logrus.Debugf("Update cluster health after %d interval", dt.Cfg.FlagPullInterval)
 	}
 }
 
@@ -487,6 +670,20 @@ func runPull(dt Dt) {
 		logrus.Errorf("Could not get master nodes: %s", err)
 	}
 
+	// in coordinated mode only the leading master pulls the cluster nodes, the other masters copy its report.
+	// If the leader's report is not available, the master takes over and pulls the cluster nodes itself.
+	if dt.Cfg.FlagCoordinatedPull {
+		ok, err := pullLeaderReport(clusterNodes, dt)
+		if ok {
+			dt.DtNodeTracker.update()
+			dt.DtHealthHistory.record()
+			return
+		}
+		if err != nil {
+			logrus.Warningf("Could not get a report from the leading master, pulling cluster nodes: %s", err)
+		}
+	}
+
 	agentNodes, err := dt.DtDCOSTools.GetAgentNodes()
 	if err != nil {
 		logrus.Errorf("Could not get agent nodes: %s", err)
@@ -494,31 +691,122 @@ func runPull(dt Dt) {
 
 	clusterNodes = append(clusterNodes, agentNodes...)
 
+	// nodes which recently pushed their health reports do not need to be pulled.
+	pushedReports := globalPushedReports.get(time.Duration(dt.Cfg.FlagPushTTL) * time.Second)
+
 	// If not nodes found we should wait for a timeout between trying the next pull.
-	if len(clusterNodes) == 0 {
+	if len(clusterNodes) == 0 && len(pushedReports) == 0 {
 		logrus.Error("Could not find master or agent nodes")
 		return
 	}
 
-	respChan := make(chan *httpResponse, len(clusterNodes))
+	respChan := make(chan *httpResponse, len(clusterNodes)+len(pushedReports))
 
 	// Pull data from each host
 	var wg sync.WaitGroup
 	for _, node := range clusterNodes {
+		if report, ok := pushedReports[node.IP]; ok {
+			logrus.Debugf("Using a pushed health report from %s", node.IP)
+			respChan <- newHostResponse(node, http.StatusOK, report, dt)
+			delete(pushedReports, node.IP)
+			continue
+		}
 		wg.Add(1)
 		go pullHostStatus(node, respChan, dt, &wg)
 	}
 	wg.Wait()
 
+	// add the nodes which pushed their reports but were not discovered, e.g. the agents behind NAT.
+	for _, report := range pushedReports {
+		respChan <- newHostResponse(pushedReportNode(report), http.StatusOK, report, dt)
+	}
+
 	// update collected units/nodes health statuses
-	updateHealthStatus(respChan)
+	updateHealthStatus(respChan, dt.Cfg.AggregationPolicies)
+	dt.DtNodeTracker.update()
+	dt.DtHealthHistory.record()
+}
+
+// pullLeaderReport updates globalMonitoringResponse with the report aggregated by the leading master. The function
+// returns false and no error if the leader is unknown or the local node is the leader, in which case the caller
+// should pull the cluster nodes itself.
+func pullLeaderReport(masterNodes []Node, dt Dt) (bool, error) {
+	var leader *Node
+	for i := range masterNodes {
+		if masterNodes[i].Leader {
+			leader = &masterNodes[i]
+			break
+		}
+	}
+	if leader == nil {
+		logrus.Debug("Leading master not found, pulling cluster nodes")
+		return false, nil
+	}
+
+	localIP, err := dt.DtDCOSTools.DetectIP()
+	if err != nil {
+		return false, err
+	}
+	if leader.IP == localIP {
+		logrus.Debug("Local node is the leading master, pulling cluster nodes")
+		return false, nil
+	}
+
+	baseURL, err := nodeBaseURL(dt.Cfg, *leader)
+	if err != nil {
+		return false, err
+	}
+	url := baseURL + BaseRoute + "/report"
+
+	timeout := time.Duration(dt.Cfg.FlagPullTimeoutSec) * time.Second
+	body, statusCode, err := dt.DtDCOSTools.Get(url, timeout)
+	if err != nil {
+		return false, err
+	}
+	if statusCode != http.StatusOK {
+		return false, fmt.Errorf("GET %s failed, status code: %d", url, statusCode)
+	}
+
+	var report monitoringResponse
+	if err := json.Unmarshal(body, &report); err != nil {
+		return false, err
+	}
+
+	// the leader might have been elected recently and did not pull the cluster yet or its puller is stuck.
+	if report.UpdatedTime.IsZero() {
+		return false, fmt.Errorf("leading master %s has not pulled the cluster yet", leader.IP)
+	}
+	maxAge := 3 * time.Duration(dt.Cfg.FlagPullInterval) * time.Second
+	if age := time.Since(report.UpdatedTime); age > maxAge {
+		return false, fmt.Errorf("report from leading master %s is outdated, last updated %s ago", leader.IP, age)
+	}
+
+	logrus.Debugf("Using the report from the leading master %s", leader.IP)
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+		Nodes:       report.Nodes,
+		Units:       report.Units,
+		UpdatedTime: report.UpdatedTime,
+	})
+	return true, nil
 }
 
 // function builds a map of all unique units with status
-func updateHealthStatus(responses <-chan *httpResponse) {
+func updateHealthStatus(responses <-chan *httpResponse, policies map[string]AggregationPolicy) {
+	units, nodes := aggregateResponses(responses, policies)
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
+		Nodes:       nodes,
+		Units:       units,
+		UpdatedTime: time.Now(),
+	})
+}
+
+// aggregateResponses reads host responses until the channel is empty and returns a map of all unique units with
+// status and a map of nodes.
+func aggregateResponses(responses <-chan *httpResponse, policies map[string]AggregationPolicy) (map[string]unit, map[string]Node) {
 	var (
-		units = make(map[string]unit)
-		nodes = make(map[string]Node)
+		units  = make(map[string]unit)
+		nodes  = make(map[string]Node)
+		states = make(map[string][]unitNodeHealth)
 	)
 
 	for {
@@ -529,6 +817,11 @@ func updateHealthStatus(responses <-chan *httpResponse) {
 			nodes[response.Node.IP] = node
 
 			for _, currentUnit := range response.Units {
+				states[currentUnit.UnitName] = append(states[currentUnit.UnitName], unitNodeHealth{
+					node:   response.Node,
+					health: currentUnit.Health,
+				})
+
 				u, ok := units[currentUnit.UnitName]
 				if ok {
 					u.Nodes = append(u.Nodes, currentUnit.Nodes...)
@@ -541,12 +834,14 @@ func updateHealthStatus(responses <-chan *httpResponse) {
 				}
 			}
 		default:
-			globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{
-				Nodes:       nodes,
-				Units:       units,
-				UpdatedTime: time.Now(),
-			})
-			return
+			// apply the aggregation policies once all responses are read.
+			for name, u := range units {
+				units[name] = aggregateUnitHealth(u, states[name], policies)
+			}
+			if u, ok := versionSkewUnit(nodes); ok {
+				units[u.UnitName] = u
+			}
+			return units, nodes
 		}
 	}
 }
@@ -554,8 +849,7 @@ func updateHealthStatus(responses <-chan *httpResponse) {
 func pullHostStatus(host Node, respChan chan<- *httpResponse, dt Dt, wg *sync.WaitGroup) {
 	defer wg.Done()
 	var response httpResponse
-	port, err := getPullPortByRole(dt.Cfg, host.Role)
-	if err != nil {
+	if _, err := getNodePort(dt.Cfg, host); err != nil {
 		logrus.Errorf("Could not get a port by role %s: %s", host.Role, err)
 		response.Status = http.StatusServiceUnavailable
 		host.Health = 3
@@ -564,29 +858,26 @@ func pullHostStatus(host Node, respChan chan<- *httpResponse, dt Dt, wg *sync.Wa
 		return
 	}
 
-	baseURL := fmt.Sprintf("http://%s:%d%s", host.IP, port, BaseRoute)
-
-	// UnitsRoute available in router.go
-	url, err := useTLSScheme(baseURL, dt.Cfg.FlagForceTLS)
-	if err != nil {
-		logrus.Errorf("Could not read useTLSScheme: %s", err)
-		response.Status = http.StatusServiceUnavailable
-		host.Health = 3
-		response.Node = host
-		respChan <- &response
-		return
-	}
-
-	// Make a request to get node units status
+	// Make a request to get node units status, UnitsRoute available in router.go
 	// use fake interface implementation for tests
+	var (
+		url        string
+		body       []byte
+		statusCode int
+	)
 	timeout := time.Duration(dt.Cfg.FlagPullTimeoutSec) * time.Second
-	body, statusCode, err := dt.DtDCOSTools.Get(url, timeout)
+	scheme, err := doNodeRequest(dt.Cfg, host, func(baseURL string) (err error) {
+		url = baseURL + BaseRoute
+		body, statusCode, err = dt.DtDCOSTools.Get(url, timeout)
+		return err
+	})
+	host.NegotiatedScheme = scheme
 	if err != nil {
 		logrus.Errorf("Could not HTTP GET %s: %s", url, err)
 		response.Status = statusCode
 		host.Health = 3 // 3 stands for unknown
-		respChan <- &response
 		response.Node = host
+		respChan <- &response
 		return
 	}
 
@@ -596,19 +887,34 @@ func pullHostStatus(host Node, respChan chan<- *httpResponse, dt Dt, wg *sync.Wa
 		logrus.Errorf("Coult not deserialize json reponse from %s, url %s: %s", host.IP, url, err)
 		response.Status = statusCode
 		host.Health = 3 // 3 stands for unknown
-		respChan <- &response
 		response.Node = host
+		respChan <- &response
 		return
 	}
-	response.Status = statusCode
+	respChan <- newHostResponse(host, statusCode, jsonBody, dt)
+}
+
+// newHostResponse builds a host response from a node health report. The report could be pulled from
+// the host or pushed by the host.
+func newHostResponse(host Node, statusCode int, jsonBody UnitsHealthResponseJSONStruct, dt Dt) *httpResponse {
+	response := &httpResponse{
+		Status: statusCode,
+	}
 
 	// Update Response and send it back to respChan
 	host.Host = jsonBody.Hostname
 
-	// update mesos node id
-	host.MesosID = jsonBody.MesosID
+	// update mesos node id, keep the id found by the discovery if the host did not report it.
+	if jsonBody.MesosID != "" {
+		host.MesosID = jsonBody.MesosID
+	}
+
+	// update DC/OS and 3dt versions running on the host
+	host.DCOSVersion = jsonBody.DcosVersion
+	host.TDTVersion = jsonBody.TdtVersion
 
 	host.Output = make(map[string]string)
+	host.Help = make(map[string]string)
 
 	// if at least one unit is not healthy, the host should be set unhealthy
 	for _, propertiesMap := range jsonBody.Array {
@@ -621,18 +927,48 @@ func pullHostStatus(host Node, respChan chan<- *httpResponse, dt Dt, wg *sync.Wa
 	for _, propertiesMap := range jsonBody.Array {
 		// update error message per host per unit
 		host.Output[propertiesMap.UnitID] = propertiesMap.UnitOutput
+
+		// a node without the knowledge base gets the help from the knowledge base of this master.
+		help := propertiesMap.Help
+		if help == "" {
+			help = dt.DtKnowledgeBase.Help(propertiesMap.UnitID, propertiesMap.UnitHealth, propertiesMap.UnitOutput)
+		}
+		if help != "" {
+			host.Help[propertiesMap.UnitID] = help
+		}
 		response.Units = append(response.Units, unit{
-			propertiesMap.UnitID,
-			[]Node{host},
-			propertiesMap.UnitHealth,
-			propertiesMap.UnitTitle,
-			dt.DtDCOSTools.GetTimestamp(),
-			propertiesMap.PrettyName,
+			UnitName:   propertiesMap.UnitID,
+			Nodes:      []Node{host},
+			Health:     propertiesMap.UnitHealth,
+			Title:      propertiesMap.UnitTitle,
+			Timestamp:  dt.DtDCOSTools.GetTimestamp(),
+			PrettyName: propertiesMap.PrettyName,
 		})
 	}
+
+	// keep the system metrics on the node only, units reference a node to report its health.
+	host.System = &jsonBody.System
 	response.Node = host
-	respChan <- &response
+	return response
+}
+
+// getNodePort returns a TCP port to connect to a node. A port set for a node by a discovery provider overrides
+// the port by the node role.
+func getNodePort(config *Config, node Node) (int, error) {
+	port, err := getPullPortByRole(config, node.Role)
+	if err != nil {
+		return port, err
+	}
+	if node.Port != 0 {
+		return node.Port, nil
+	}
+	return port, nil
+}
 
+// nodeBaseURL returns a URL to connect to 3dt on a node, e.g. http://10.0.0.1:1050. The scheme is the first scheme
+// allowed by the TLS mode, see nodeSchemesToTry.
+func nodeBaseURL(config *Config, node Node) (string, error) {
+	return nodeURLWithScheme(config, node, nodeSchemesToTry(config, node)[0])
 }
 
 func getPullPortByRole(config *Config, role string) (int, error) {
@@ -1,11 +1,24 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	// intentionally rename package to do some magic
 	assertPackage "github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 type PullerTestSuit struct {
@@ -25,6 +38,7 @@ func (s *PullerTestSuit) SetupTest() {
 
 func (s *PullerTestSuit) TearDownTest() {
 	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{})
+	globalPushedReports.get(0)
 }
 
 // TestMonitoringResponseRace checks that the various exported methods
@@ -92,10 +106,11 @@ func (s *PullerTestSuit) TestPullerFindUnit() {
 	unit, err := globalMonitoringResponse.GetUnit("dcos-master.service")
 	s.assert.Nil(err)
 	s.assert.Equal(unit, unitResponseFieldsStruct{
-		"dcos-master.service",
-		"PrettyName",
-		0,
-		"Nice Master Description.",
+		UnitID:      "dcos-master.service",
+		PrettyName:  "PrettyName",
+		UnitHealth:  0,
+		UnitTitle:   "Nice Master Description.",
+		Aggregation: &unitAggregation{Policy: AggregationAny, Healthy: 1},
 	})
 }
 
@@ -106,6 +121,709 @@ func (s *PullerTestSuit) TestPullerNotFindUnit() {
 	s.assert.Equal(unit, unitResponseFieldsStruct{})
 }
 
+func (s *PullerTestSuit) TestPullLeaderReport() {
+	cfg := testCfg
+	cfg.FlagCoordinatedPull = true
+
+	st := &fakeDCOSTools{
+		fakeMasters: []Node{
+			{IP: "10.0.7.190", Role: MasterRole, Leader: true},
+			{IP: "127.0.0.1", Role: MasterRole},
+		},
+	}
+	report := fmt.Sprintf(`{"Units": {"dcos-leader.service": {"UnitName": "dcos-leader.service", "Health": 1}},
+		"Nodes": {"10.0.7.190": {"IP": "10.0.7.190", "Role": "master"}}, "UpdatedTime": "%s"}`,
+		time.Now().Format(time.RFC3339Nano))
+	st.makeMockedResponse("http://10.0.7.190:1050/system/health/v1/report", []byte(report), http.StatusOK, nil)
+
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+
+	// the report should be copied from the leader and the cluster nodes should not be pulled.
+	unit, err := globalMonitoringResponse.GetUnit("dcos-leader.service")
+	s.assert.Nil(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+	s.assert.Equal(st.getRequestsMade, []string{"http://10.0.7.190:1050/system/health/v1/report"})
+}
+
+func (s *PullerTestSuit) TestPullLeaderReportFailover() {
+	cfg := testCfg
+	cfg.FlagCoordinatedPull = true
+
+	st := &fakeDCOSTools{
+		fakeMasters: []Node{
+			{IP: "10.0.7.190", Role: MasterRole, Leader: true},
+			{IP: "127.0.0.1", Role: MasterRole},
+		},
+	}
+	st.makeMockedResponse("http://10.0.7.190:1050/system/health/v1/report", nil, http.StatusBadRequest,
+		errors.New("connection refused"))
+
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+
+	// the leader is not available, the local master should pull the cluster nodes.
+	_, err := globalMonitoringResponse.GetUnit("dcos-agent.service")
+	s.assert.Nil(err)
+	s.assert.Contains(st.getRequestsMade, "http://127.0.0.2:1050/system/health/v1")
+}
+
+func (s *PullerTestSuit) TestPullLocalLeader() {
+	cfg := testCfg
+	cfg.FlagCoordinatedPull = true
+
+	st := &fakeDCOSTools{
+		fakeMasters: []Node{
+			{IP: "127.0.0.1", Role: MasterRole, Leader: true},
+		},
+	}
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+
+	// the local node is the leader, it should pull the cluster nodes.
+	s.assert.NotContains(st.getRequestsMade, "http://127.0.0.1:1050/system/health/v1/report")
+	s.assert.Contains(st.getRequestsMade, "http://127.0.0.2:1050/system/health/v1")
+}
+
+func (s *PullerTestSuit) TestPullUsesPushedReport() {
+	globalPushedReports.add(UnitsHealthResponseJSONStruct{
+		Array: []healthResponseValues{
+			{UnitID: "dcos-pushed.service", UnitHealth: 1},
+		},
+		IPAddress: "127.0.0.2",
+		Role:      AgentRole,
+	})
+	st := &fakeDCOSTools{}
+	runPull(Dt{Cfg: &testCfg, DtDCOSTools: st})
+
+	// the agent pushed its report, it should not be pulled.
+	s.assert.NotContains(st.getRequestsMade, "http://127.0.0.2:1050/system/health/v1")
+	unit, err := globalMonitoringResponse.GetUnit("dcos-pushed.service")
+	s.assert.Nil(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+	_, err = globalMonitoringResponse.GetNodeByID("127.0.0.2")
+	s.assert.Nil(err)
+}
+
+func (s *PullerTestSuit) TestPushedReportNotDiscovered() {
+	globalPushedReports.add(UnitsHealthResponseJSONStruct{
+		Array: []healthResponseValues{
+			{UnitID: "dcos-pushed.service", UnitHealth: 0},
+		},
+		IPAddress: "10.0.7.200",
+		Role:      AgentPublicRole,
+	})
+	runPull(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}})
+
+	node, err := globalMonitoringResponse.GetNodeByID("10.0.7.200")
+	s.assert.Nil(err)
+	s.assert.Equal(node.NodeRole, AgentPublicRole)
+}
+
+func (s *PullerTestSuit) TestPushedReportExpired() {
+	globalPushedReports.add(UnitsHealthResponseJSONStruct{IPAddress: "10.0.7.200"})
+	s.assert.Len(globalPushedReports.get(time.Minute), 1)
+	s.assert.Len(globalPushedReports.get(0), 0)
+	s.assert.Len(globalPushedReports.get(time.Minute), 0)
+}
+
+func (s *PullerTestSuit) TestPushHealthReport() {
+	cfg := testCfg
+	master := httptest.NewServer(NewRouter(Dt{Cfg: &cfg, DtDCOSTools: &fakeDCOSTools{}}))
+	defer master.Close()
+
+	masterURL, err := url.Parse(master.URL)
+	s.assert.NoError(err)
+	cfg.FlagMasterPort, err = strconv.Atoi(masterURL.Port())
+	s.assert.NoError(err)
+
+	err = pushHealthReport(Dt{
+		Cfg:          &cfg,
+		DtDCOSTools:  &fakeDCOSTools{fakeMasters: []Node{{IP: "127.0.0.1", Role: MasterRole}}},
+		SystemdUnits: &SystemdUnits{},
+	})
+	s.assert.NoError(err)
+
+	// fakeDCOSTools detects 127.0.0.1 as a local IP address.
+	reports := globalPushedReports.get(time.Minute)
+	s.assert.Contains(reports, "127.0.0.1")
+	s.assert.Equal(reports["127.0.0.1"].Hostname, "MyHostName")
+}
+
+func (s *PullerTestSuit) TestRefreshCoalesced() {
+	refresher := NewPullRefresher()
+	release := make(chan struct{})
+	var runs int
+
+	first := refresher.refresh(refreshAll, func() {
+		runs++
+		<-release
+	})
+	second := refresher.refresh(refreshAll, func() {
+		runs++
+	})
+	s.assert.Equal(first, second)
+
+	close(release)
+	<-first
+	s.assert.Equal(runs, 1)
+
+	// the next refresh should run again.
+	<-refresher.refresh(refreshAll, func() {
+		runs++
+	})
+	s.assert.Equal(runs, 2)
+}
+
+func (s *PullerTestSuit) TestRefreshSerialized() {
+	refresher := NewPullRefresher()
+	started, release := make(chan struct{}), make(chan struct{})
+	var events []string
+	var mu sync.Mutex
+	event := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+
+	node := refresher.refresh("node:127.0.0.2", func() {
+		close(started)
+		<-release
+		event("node")
+	})
+	<-started
+	all := refresher.refresh(refreshAll, func() {
+		event("all")
+	})
+
+	// the full refresh waits for the targeted refresh to publish.
+	select {
+	case <-all:
+		s.Fail("full refresh ran concurrently with a targeted refresh")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-node
+	<-all
+	s.assert.Equal([]string{"node", "all"}, events)
+}
+
+func (s *PullerTestSuit) TestRefreshNode() {
+	cfg := testCfg
+	cfg.FlagPull = true
+	st := &fakeDCOSTools{}
+	router := NewRouter(Dt{Cfg: &cfg, DtDCOSTools: st, PullRefresher: NewPullRefresher()})
+
+	_, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/nodes/127.0.0.2?cache=0", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+
+	// only the requested node should be pulled
+	s.assert.Equal(st.getRequestsMade, []string{"http://127.0.0.2:1050/system/health/v1"})
+
+	// units from the rest of the nodes should be kept
+	_, err = globalMonitoringResponse.GetUnit("dcos-master.service")
+	s.assert.Nil(err)
+	unit, err := globalMonitoringResponse.GetUnit("dcos-agent.service")
+	s.assert.Nil(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+}
+
+func (s *PullerTestSuit) TestRefreshUnit() {
+	cfg := testCfg
+	cfg.FlagPull = true
+	st := &fakeDCOSTools{}
+	router := NewRouter(Dt{Cfg: &cfg, DtDCOSTools: st, PullRefresher: NewPullRefresher()})
+
+	_, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/units/dcos-master.service?cache=0", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+	s.assert.Equal(st.getRequestsMade, []string{"http://127.0.0.1:1050/system/health/v1"})
+
+	// unknown node should refresh the entire cluster
+	st.getRequestsMade = nil
+	_, code, err = MakeHTTPRequest(s.T(), router, "/system/health/v1/nodes/10.0.7.1?cache=0", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusNotFound)
+	s.assert.Len(st.getRequestsMade, 2)
+	s.assert.True(st.nodesCacheInvalidated)
+}
+
+func (s *PullerTestSuit) TestRefreshWithoutPull() {
+	router := NewRouter(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}, PullRefresher: NewPullRefresher()})
+	_, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/units?cache=0", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusServiceUnavailable)
+}
+
+func (s *PullerTestSuit) TestPullPreferTLS() {
+	defer func() {
+		globalNodeSchemes = &nodeSchemes{schemes: make(map[string]negotiatedScheme)}
+	}()
+
+	cfg := testCfg
+	cfg.FlagTLSMode = TLSModePrefer
+	st := &fakeDCOSTools{}
+	st.makeMockedResponse("https://127.0.0.1:1050/system/health/v1", nil, 0,
+		errors.New("http: server gave HTTP response to HTTPS client"))
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+
+	// the master does not support TLS yet, the agent does
+	s.assert.Contains(st.getRequestsMade, "https://127.0.0.1:1050/system/health/v1")
+	s.assert.Contains(st.getRequestsMade, "http://127.0.0.1:1050/system/health/v1")
+	s.assert.Contains(st.getRequestsMade, "https://127.0.0.2:1050/system/health/v1")
+	s.assert.NotContains(st.getRequestsMade, "http://127.0.0.2:1050/system/health/v1")
+
+	node, err := globalMonitoringResponse.GetNodeByID("127.0.0.1")
+	s.assert.NoError(err)
+	s.assert.Equal(node.HostIP, "127.0.0.1")
+	globalMonitoringResponse.RLock()
+	s.assert.Equal(globalMonitoringResponse.Nodes["127.0.0.1"].NegotiatedScheme, "http")
+	globalMonitoringResponse.RUnlock()
+
+	// the negotiated scheme is used first
+	st.getRequestsMade = nil
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+	s.assert.NotContains(st.getRequestsMade, "https://127.0.0.1:1050/system/health/v1")
+	s.assert.Contains(st.getRequestsMade, "http://127.0.0.1:1050/system/health/v1")
+
+	// https is tried first again once the negotiated http expires
+	globalNodeSchemes.Lock()
+	globalNodeSchemes.schemes["127.0.0.1"] = negotiatedScheme{scheme: "http",
+		negotiated: time.Now().Add(-nodeSchemeHTTPTTL - time.Second)}
+	globalNodeSchemes.Unlock()
+	s.assert.Equal(nodeSchemesToTry(&cfg, Node{IP: "127.0.0.1"}), []string{"https", "http"})
+	st.getRequestsMade = nil
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: st})
+	s.assert.Contains(st.getRequestsMade, "https://127.0.0.1:1050/system/health/v1")
+	s.assert.Equal(nodeSchemesToTry(&cfg, Node{IP: "127.0.0.1"}), []string{"http", "https"})
+
+	// the mode is ignored if TLS is forced
+	cfg.FlagForceTLS = true
+	s.assert.Equal(nodeSchemesToTry(&cfg, Node{IP: "127.0.0.1"}), []string{"https"})
+}
+
+func (s *PullerTestSuit) TestPreferTLSCertificateError() {
+	defer func() {
+		globalNodeSchemes = &nodeSchemes{schemes: make(map[string]negotiatedScheme)}
+	}()
+
+	// the node certificate is not signed by a trusted CA.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := testCfg
+	cfg.FlagTLSMode = TLSModePrefer
+	var tried []string
+	_, err := doNodeRequest(&cfg, Node{IP: "127.0.0.1", Role: MasterRole}, func(baseURL string) error {
+		tried = append(tried, baseURL)
+		if !strings.HasPrefix(baseURL, "https://") {
+			return nil
+		}
+		resp, err := http.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	})
+
+	// the node fails, http is not tried and not remembered.
+	s.assert.Error(err)
+	s.assert.True(isCertificateError(err))
+	s.assert.Equal([]string{"https://127.0.0.1:1050"}, tried)
+	_, ok := globalNodeSchemes.get("127.0.0.1")
+	s.assert.False(ok)
+
+	// other errors fail the node too, only a node which does not speak TLS falls back to http.
+	tried = nil
+	_, err = doNodeRequest(&cfg, Node{IP: "127.0.0.1", Role: MasterRole}, func(baseURL string) error {
+		tried = append(tried, baseURL)
+		return errors.New("i/o timeout")
+	})
+	s.assert.Error(err)
+	s.assert.Len(tried, 1)
+
+	tried = nil
+	scheme, err := doNodeRequest(&cfg, Node{IP: "127.0.0.1", Role: MasterRole}, func(baseURL string) error {
+		tried = append(tried, baseURL)
+		if strings.HasPrefix(baseURL, "https://") {
+			return syscall.ECONNREFUSED
+		}
+		return nil
+	})
+	s.assert.NoError(err)
+	s.assert.Equal("http", scheme)
+	s.assert.Len(tried, 2)
+}
+
+func (s *PullerTestSuit) TestNodeTrackerMissingNodes() {
+	dir, err := ioutil.TempDir("", "3dt-known-nodes")
+	s.assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := testCfg
+	cfg.FlagPull = true
+	cfg.FlagKnownNodesFile = filepath.Join(dir, "known-nodes.json")
+	tracker := &NodeTracker{}
+	s.assert.NoError(tracker.Init(&cfg))
+	dt := Dt{Cfg: &cfg, DtDCOSTools: &fakeDCOSTools{}, DtNodeTracker: tracker}
+	runPull(dt)
+
+	unit, err := globalMonitoringResponse.GetUnit(missingNodesUnitName)
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 0)
+
+	// the known nodes are saved only when they change
+	_, err = os.Stat(cfg.FlagKnownNodesFile)
+	s.assert.NoError(err)
+	s.assert.NoError(os.Remove(cfg.FlagKnownNodesFile))
+	tracker.update()
+	_, err = os.Stat(cfg.FlagKnownNodesFile)
+	s.assert.True(os.IsNotExist(err))
+
+	// the agent is not discovered anymore
+	globalMonitoringResponse.RLock()
+	nodes := map[string]Node{"127.0.0.1": globalMonitoringResponse.Nodes["127.0.0.1"]}
+	units := globalMonitoringResponse.Units
+	globalMonitoringResponse.RUnlock()
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{Nodes: nodes, Units: units, UpdatedTime: time.Now()})
+	tracker.update()
+
+	unit, err = globalMonitoringResponse.GetUnit(missingNodesUnitName)
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+	s.assert.Contains(unit.UnitTitle, "1 nodes are missing: 127.0.0.2")
+
+	// the known nodes are loaded after a restart
+	restarted := &NodeTracker{}
+	s.assert.NoError(restarted.Init(&cfg))
+	known := restarted.GetKnownNodes()
+	s.assert.Len(known.Array, 2)
+	s.assert.False(known.Array[0].Missing)
+	s.assert.True(known.Array[1].Missing)
+	s.assert.Equal(known.Array[1].Role, AgentRole)
+
+	router := NewRouter(dt)
+	for url, expectedCode := range map[string]int{
+		"/system/health/v1/nodes/127.0.0.1": http.StatusConflict,
+		"/system/health/v1/nodes/10.0.0.1":  http.StatusNotFound,
+		"/system/health/v1/nodes/127.0.0.2": http.StatusOK,
+	} {
+		_, code, err := MakeHTTPRequest(s.T(), router, url, "DELETE", nil)
+		s.assert.NoError(err)
+		s.assert.Equal(code, expectedCode, url)
+	}
+
+	unit, err = globalMonitoringResponse.GetUnit(missingNodesUnitName)
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 0)
+
+	response, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/known-nodes", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+	var knownNodes knownNodesResponseJSONStruct
+	s.assert.NoError(json.Unmarshal(response, &knownNodes))
+	s.assert.Len(knownNodes.Array, 1)
+	s.assert.Equal(knownNodes.Array[0].IP, "127.0.0.1")
+}
+
+func (s *PullerTestSuit) TestHealthHistoryAvailability() {
+	dir, err := ioutil.TempDir("", "3dt-history")
+	s.assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := testCfg
+	cfg.FlagPull = true
+	cfg.FlagHistoryFile = filepath.Join(dir, "health-history.json")
+	history := &HealthHistory{}
+	s.assert.NoError(history.Init(&cfg))
+	history.record()
+
+	// the same report must not be counted twice
+	history.record()
+
+	// make dcos-master.service unhealthy
+	globalMonitoringResponse.Lock()
+	u := globalMonitoringResponse.Units["dcos-master.service"]
+	u.Health = 1
+	globalMonitoringResponse.Units["dcos-master.service"] = u
+	globalMonitoringResponse.UpdatedTime = globalMonitoringResponse.UpdatedTime.Add(time.Second)
+	globalMonitoringResponse.Unlock()
+
+	// a report updated within a pull interval, e.g. by a pushed report, is not sampled
+	history.record()
+	availability, err := history.GetUnitAvailability("dcos-master.service", 24*time.Hour)
+	s.assert.NoError(err)
+	s.assert.Equal(availability.Samples, 1)
+
+	globalMonitoringResponse.Lock()
+	globalMonitoringResponse.UpdatedTime = globalMonitoringResponse.UpdatedTime.Add(time.Duration(cfg.FlagPullInterval) * time.Second)
+	globalMonitoringResponse.Unlock()
+	history.record()
+
+	availability, err = history.GetUnitAvailability("dcos-master.service", 24*time.Hour)
+	s.assert.NoError(err)
+	s.assert.Equal(availability.Samples, 2)
+	s.assert.Equal(availability.HealthySamples, 1)
+	s.assert.Equal(availability.AvailabilityPercent, float64(50))
+
+	_, err = history.GetUnitAvailability("dcos-unknown.service", 24*time.Hour)
+	s.assert.Error(err)
+
+	// a new instance must load the history and the last report from disk
+	globalMonitoringResponse.updateMonitoringResponse(monitoringResponse{})
+	restored := &HealthHistory{}
+	s.assert.NoError(restored.Init(&cfg))
+	availability, err = restored.GetUnitAvailability("dcos-master.service", 24*time.Hour)
+	s.assert.NoError(err)
+	s.assert.Equal(availability.Samples, 2)
+
+	unitResponse, err := globalMonitoringResponse.GetUnit("dcos-master.service")
+	s.assert.NoError(err)
+	s.assert.Equal(unitResponse.UnitHealth, 1)
+}
+
+func (s *PullerTestSuit) TestAvailabilityHandler() {
+	history := &HealthHistory{}
+	s.assert.NoError(history.Init(&Config{FlagHistoryResolutionMinutes: 60, FlagHistoryRetentionDays: 35}))
+	history.record()
+	router := NewRouter(Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}, DtHealthHistory: history})
+
+	response, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/nodes/127.0.0.1/availability?window=7d", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+
+	var availability availabilityResponse
+	s.assert.NoError(json.Unmarshal(response, &availability))
+	s.assert.Equal(availability.ID, "127.0.0.1")
+	s.assert.Equal(availability.Window, "168h0m0s")
+	s.assert.Equal(availability.Samples, 1)
+
+	_, code, err = MakeHTTPRequest(s.T(), router, "/system/health/v1/units/dcos-master.service/availability?window=week", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusBadRequest)
+
+	_, code, err = MakeHTTPRequest(s.T(), router, "/system/health/v1/units/dcos-unknown.service/availability", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusNotFound)
+}
+
+func (s *PullerTestSuit) TestParseWindow() {
+	for window, expected := range map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"4w":  28 * 24 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		d, err := parseWindow(window)
+		s.assert.NoError(err)
+		s.assert.Equal(d, expected)
+	}
+
+	for _, window := range []string{"", "d", "-1d", "0h", "month"} {
+		_, err := parseWindow(window)
+		s.assert.Error(err, window)
+	}
+}
+
+func (s *PullerTestSuit) TestNodeSystemMetrics() {
+	system, err := globalMonitoringResponse.GetNodeSystem("127.0.0.2")
+	s.assert.NoError(err)
+	s.assert.Equal(system.Role, AgentRole)
+	s.assert.Equal(system.System.Memory.UsedPercent, float64(80))
+	s.assert.Equal(system.System.LoadAvarage.Load1, 2.5)
+
+	// units must not carry the node metrics
+	for _, u := range globalMonitoringResponse.Units {
+		for _, node := range u.Nodes {
+			s.assert.Nil(node.System)
+		}
+	}
+
+	router := NewRouter(s.dt)
+	_, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/nodes/10.0.7.1/system", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusNotFound)
+}
+
+func (s *PullerTestSuit) TestSystemTop() {
+	top := globalMonitoringResponse.GetSystemTop(10, "")
+	s.assert.Equal(top.Disk, []nodeUsageResponseFieldsStruct{
+		{HostIP: "127.0.0.1", Role: MasterRole, Value: 70, Path: "/var/lib"},
+		{HostIP: "127.0.0.2", Role: AgentRole, Value: 30, Path: "/"},
+	})
+	s.assert.Equal(top.Memory[0].HostIP, "127.0.0.2")
+	s.assert.Equal(top.Load[0].HostIP, "127.0.0.2")
+
+	top = globalMonitoringResponse.GetSystemTop(1, MasterRole)
+	s.assert.Len(top.Disk, 1)
+	s.assert.Equal(top.Memory, []nodeUsageResponseFieldsStruct{{HostIP: "127.0.0.1", Role: MasterRole, Value: 40}})
+
+	router := NewRouter(s.dt)
+	response, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/system/top?limit=1&role=agent", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+	s.assert.NoError(json.Unmarshal(response, &top))
+	s.assert.Equal(top.Load, []nodeUsageResponseFieldsStruct{{HostIP: "127.0.0.2", Role: AgentRole, Value: 2.5}})
+
+	for _, query := range []string{"limit=0", "limit=ten", "role=slave"} {
+		_, code, err = MakeHTTPRequest(s.T(), router, "/system/health/v1/system/top?"+query, "GET", nil)
+		s.assert.NoError(err)
+		s.assert.Equal(code, http.StatusBadRequest, query)
+	}
+}
+
+func (s *PullerTestSuit) TestAggregationPolicies() {
+	states := []unitNodeHealth{
+		{node: Node{IP: "10.0.7.1", Role: MasterRole, Leader: true}, health: 0},
+		{node: Node{IP: "10.0.7.2", Role: MasterRole}, health: 1},
+		{node: Node{IP: "10.0.7.3", Role: MasterRole}, health: 0},
+		{node: Node{IP: "10.0.7.4", Role: AgentRole}, health: 1},
+	}
+
+	for _, tc := range []struct {
+		policy      AggregationPolicy
+		health      int
+		aggregation unitAggregation
+	}{
+		{
+			policy:      AggregationPolicy{Policy: AggregationAny},
+			health:      1,
+			aggregation: unitAggregation{Policy: AggregationAny, Healthy: 2, Unhealthy: 2},
+		},
+		{
+			policy:      AggregationPolicy{Policy: AggregationMajority},
+			health:      0,
+			aggregation: unitAggregation{Policy: AggregationMajority, Role: MasterRole, Healthy: 2, Unhealthy: 1},
+		},
+		{
+			policy:      AggregationPolicy{Policy: AggregationMajority, Role: AgentRole},
+			health:      1,
+			aggregation: unitAggregation{Policy: AggregationMajority, Role: AgentRole, Unhealthy: 1},
+		},
+		{
+			policy:      AggregationPolicy{Policy: AggregationPercentage, Percent: 50},
+			health:      0,
+			aggregation: unitAggregation{Policy: AggregationPercentage, Percent: 50, Healthy: 2, Unhealthy: 2},
+		},
+		{
+			policy:      AggregationPolicy{Policy: AggregationPercentage, Percent: 75},
+			health:      1,
+			aggregation: unitAggregation{Policy: AggregationPercentage, Percent: 75, Healthy: 2, Unhealthy: 2},
+		},
+		{
+			policy:      AggregationPolicy{Policy: AggregationLeader},
+			health:      0,
+			aggregation: unitAggregation{Policy: AggregationLeader, Healthy: 1},
+		},
+		{
+			// no nodes with the role, the default policy must be used
+			policy:      AggregationPolicy{Policy: AggregationMajority, Role: AgentPublicRole},
+			health:      1,
+			aggregation: unitAggregation{Policy: AggregationAny, Healthy: 2, Unhealthy: 2},
+		},
+	} {
+		u := aggregateUnitHealth(unit{UnitName: "dcos-test.service"}, states,
+			map[string]AggregationPolicy{"dcos-test.service": tc.policy})
+		s.assert.Equal(u.Health, tc.health, tc.policy)
+		s.assert.Equal(*u.Aggregation, tc.aggregation, tc.policy)
+	}
+}
+
+func (s *PullerTestSuit) TestPullWithAggregationPolicy() {
+	cfg := testCfg
+	cfg.AggregationPolicies = map[string]AggregationPolicy{
+		"dcos-agent.service": {Policy: AggregationPercentage, Percent: 0},
+	}
+	runPull(Dt{Cfg: &cfg, DtDCOSTools: &fakeDCOSTools{}})
+
+	unit, err := globalMonitoringResponse.GetUnit("dcos-agent.service")
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 0)
+	s.assert.Equal(unit.Aggregation.Unhealthy, 1)
+
+	// node health is not affected by the unit aggregation policy
+	node, err := globalMonitoringResponse.GetNodeByID("127.0.0.2")
+	s.assert.NoError(err)
+	s.assert.Equal(node.NodeHealth, 1)
+}
+
+func (s *PullerTestSuit) TestVersionSkew() {
+	versions := globalMonitoringResponse.GetVersions()
+	s.assert.False(versions.Skew)
+	s.assert.Len(versions.DCOSVersions, 1)
+	s.assert.Equal(versions.TDTVersions[0].Version, "0.0.7")
+	s.assert.Len(versions.TDTVersions[0].Nodes, 2)
+
+	unit, err := globalMonitoringResponse.GetUnit(versionSkewUnitName)
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 0)
+
+	// the agent was not upgraded yet
+	st := &fakeDCOSTools{}
+	st.makeMockedResponse("http://127.0.0.2:1050/system/health/v1", []byte(`{
+		"units": [{"id": "dcos-agent.service", "health": 0}],
+		"ip": "127.0.0.2",
+		"dcos_version": "1.5",
+		"node_role": "agent",
+		"3dt_version": "0.0.7"
+	}`), http.StatusOK, nil)
+	runPull(Dt{Cfg: &testCfg, DtDCOSTools: st})
+
+	nodes, err := globalMonitoringResponse.GetNodesForUnit(versionSkewUnitName)
+	s.assert.NoError(err)
+	s.assert.Len(nodes.Array, 1)
+	s.assert.Equal(nodes.Array[0].HostIP, "127.0.0.2")
+
+	// the unit does not copy the node units
+	globalMonitoringResponse.RLock()
+	skewed := globalMonitoringResponse.Units[versionSkewUnitName].Nodes
+	globalMonitoringResponse.RUnlock()
+	s.assert.Len(skewed, 1)
+	s.assert.Equal(skewed[0].DCOSVersion, "1.5")
+	s.assert.Nil(skewed[0].Units)
+	s.assert.Nil(skewed[0].System)
+
+	unit, err = globalMonitoringResponse.GetUnit(versionSkewUnitName)
+	s.assert.NoError(err)
+	s.assert.Equal(unit.UnitHealth, 1)
+	s.assert.Contains(unit.UnitTitle, "127.0.0.2 (DC/OS 1.5, 3DT 0.0.7)")
+
+	router := NewRouter(s.dt)
+	response, code, err := MakeHTTPRequest(s.T(), router, "/system/health/v1/versions", "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, http.StatusOK)
+	s.assert.NoError(json.Unmarshal(response, &versions))
+	s.assert.True(versions.Skew)
+	s.assert.Equal(versions.DCOSVersions, []versionGroup{
+		{Version: "1.5", Nodes: []*nodeResponseFieldsStruct{{HostIP: "127.0.0.2", NodeRole: AgentRole}}},
+		{Version: "1.6", Nodes: []*nodeResponseFieldsStruct{{HostIP: "127.0.0.1", NodeRole: MasterRole}}},
+	})
+}
+
+func (s *PullerTestSuit) TestReferenceVersion() {
+	nodes := map[string]Node{
+		"10.0.7.1": {IP: "10.0.7.1", Role: MasterRole, DCOSVersion: "1.10"},
+		"10.0.7.2": {IP: "10.0.7.2", Role: AgentRole, DCOSVersion: "1.9"},
+		"10.0.7.3": {IP: "10.0.7.3", Role: AgentRole, DCOSVersion: "1.9"},
+		"10.0.7.4": {IP: "10.0.7.4", Role: AgentRole},
+	}
+	s.assert.Equal(referenceVersion(nodes, nodeDCOSVersion), "1.10")
+	s.assert.Equal(referenceVersion(nodes, nodeTDTVersion), "")
+
+	delete(nodes, "10.0.7.1")
+	s.assert.Equal(referenceVersion(nodes, nodeDCOSVersion), "1.9")
+
+	// versions are compared numerically if the numbers of nodes are equal
+	nodes = map[string]Node{
+		"10.0.7.1": {IP: "10.0.7.1", Role: MasterRole, DCOSVersion: "1.9"},
+		"10.0.7.2": {IP: "10.0.7.2", Role: MasterRole, DCOSVersion: "1.10"},
+	}
+	s.assert.Equal(referenceVersion(nodes, nodeDCOSVersion), "1.10")
+	s.assert.Equal(compareVersions("1.9.1", "1.9"), 1)
+	s.assert.Equal(compareVersions("1.10.0", "1.10.0"), 0)
+	s.assert.Equal(compareVersions("1.10-dev", "1.9"), 1)
+
+	_, ok := versionSkewUnit(map[string]Node{"10.0.7.4": {IP: "10.0.7.4"}})
+	s.assert.False(ok)
+}
+
 func (s *PullerTestSuit) TestHTTPReqLoadCA() {
 	h := HTTPReq{}
 	h.Init(&testCfg, &fakeDCOSTools{})
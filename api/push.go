@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// pushTokenHeader is a request header with the push token set by -push-token.
+const pushTokenHeader = "X-3DT-Push-Token"
+
+// maxPushedReportSize is the largest pushed health report accepted, in bytes.
+const maxPushedReportSize = 4 << 20
+
+// globalPushedReports a global variable updated by nodes pushing their health reports.
+var globalPushedReports = pushedReports{
+	reports: make(map[string]pushedReport),
+}
+
+// pushedReports stores the health reports pushed by nodes, keyed by a node IP address.
+type pushedReports struct {
+	sync.Mutex
+	reports map[string]pushedReport
+}
+
+type pushedReport struct {
+	report   UnitsHealthResponseJSONStruct
+	received time.Time
+}
+
+func (p *pushedReports) add(report UnitsHealthResponseJSONStruct) {
+	p.Lock()
+	defer p.Unlock()
+	p.reports[report.IPAddress] = pushedReport{
+		report:   report,
+		received: time.Now(),
+	}
+}
+
+// get returns the reports received within ttl, keyed by a node IP address. Expired reports are removed.
+func (p *pushedReports) get(ttl time.Duration) map[string]UnitsHealthResponseJSONStruct {
+	p.Lock()
+	defer p.Unlock()
+	reports := make(map[string]UnitsHealthResponseJSONStruct)
+	for ip, pushed := range p.reports {
+		if time.Since(pushed.received) > ttl {
+			logrus.Debugf("Pushed health report from %s expired", ip)
+			delete(p.reports, ip)
+			continue
+		}
+		reports[ip] = pushed.report
+	}
+	return reports
+}
+
+// pushingNode returns the discovered node which pushed a report. With a push token a node is identified by the Mesos ID
+// or the IP address in its report, the token proves the report comes from a cluster node, so the nodes behind NAT
+// can push. Without a push token a node is identified by its remote address and a report of another node is rejected.
+func pushingNode(r *http.Request, report UnitsHealthResponseJSONStruct, token string, tools DCOSHelper) (Node, error) {
+	var id string
+	var match func(Node) bool
+	if token != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(pushTokenHeader)), []byte(token)) != 1 {
+			return Node{}, forbiddenError("Invalid push token from %s", r.RemoteAddr)
+		}
+		if report.MesosID != "" {
+			id = report.MesosID
+			match = func(node Node) bool { return node.MesosID == report.MesosID }
+		} else if report.IPAddress != "" {
+			id = report.IPAddress
+			match = func(node Node) bool { return node.IP == report.IPAddress }
+		} else {
+			return Node{}, invalidArgumentError("Health report has neither a Mesos ID nor an IP address")
+		}
+	} else {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return Node{}, invalidArgumentError("Could not get a remote address: %s", err)
+		}
+		if report.IPAddress != "" && report.IPAddress != host {
+			return Node{}, forbiddenError("Node %s cannot push a health report of %s", host, report.IPAddress)
+		}
+		id = host
+		match = func(node Node) bool { return node.IP == host }
+	}
+
+	masters, mastersErr := tools.GetMasterNodes()
+	agents, agentsErr := tools.GetAgentNodes()
+	for _, node := range append(masters, agents...) {
+		if match(node) {
+			return node, nil
+		}
+	}
+	if mastersErr != nil || agentsErr != nil {
+		return Node{}, unavailableError("Could not discover the cluster nodes to accept a report from %s", id)
+	}
+	return Node{}, forbiddenError("Node %s is not found by the discovery", id)
+}
+
+// pushedReportNode returns a node for a pushed report if the node is not found by the discovery anymore. The report
+// IP address and role are set by the discovery when the report is accepted.
+func pushedReportNode(report UnitsHealthResponseJSONStruct) Node {
+	role := report.Role
+	if role == "" {
+		role = AgentRole
+	}
+	return Node{
+		IP:   report.IPAddress,
+		Role: role,
+	}
+}
+
+// publishPushedReports updates globalMonitoringResponse with pushed reports only. It is used on masters
+// which do not pull the cluster nodes.
+func publishPushedReports(dt Dt) {
+	pushedReports := globalPushedReports.get(time.Duration(dt.Cfg.FlagPushTTL) * time.Second)
+	respChan := make(chan *httpResponse, len(pushedReports))
+	for _, report := range pushedReports {
+		respChan <- newHostResponse(pushedReportNode(report), http.StatusOK, report, dt)
+	}
+	updateHealthStatus(respChan, dt.Cfg.AggregationPolicies)
+	dt.DtNodeTracker.update()
+	dt.DtHealthHistory.record()
+}
+
+// StartPushWithInterval will start to push a local health report to DC/OS masters.
+func StartPushWithInterval(dt Dt) {
+	for {
+		if err := pushHealthReport(dt); err != nil {
+			logrus.Errorf("Could not push a health report: %s", err)
+		}
+		time.Sleep(time.Duration(dt.Cfg.FlagPushInterval) * time.Second)
+	}
+}
+
+// pushHealthReport sends a local health report to every master found by the discovery.
+func pushHealthReport(dt Dt) error {
+	report, err := dt.SystemdUnits.GetUnitsProperties(dt.Cfg, dt.DtDCOSTools, dt.DtKnowledgeBase)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	masterNodes, err := dt.DtDCOSTools.GetMasterNodes()
+	if err != nil {
+		return err
+	}
+
+	var pushed int
+	timeout := time.Duration(dt.Cfg.FlagPullTimeoutSec) * time.Second
+	for _, master := range masterNodes {
+		baseURL, err := nodeBaseURL(dt.Cfg, master)
+		if err != nil {
+			return err
+		}
+		url := baseURL + BaseRoute + "/push"
+
+		request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-type", "application/json")
+		if dt.Cfg.FlagPushToken != "" {
+			request.Header.Set(pushTokenHeader, dt.Cfg.FlagPushToken)
+		}
+
+		resp, err := Requester.Do(request, timeout)
+		if err != nil {
+			logrus.Errorf("Could not push a health report to %s: %s", url, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			logrus.Errorf("Could not push a health report to %s, status code: %d", url, resp.StatusCode)
+			continue
+		}
+		pushed++
+	}
+
+	if pushed == 0 {
+		return errors.New("health report was not accepted by any master")
+	}
+	logrus.Debugf("Pushed a health report to %d masters", pushed)
+	return nil
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listQuery filters, sorts and paginates units and nodes responses. Filters are set as query parameters with
+// a comparison operator, e.g. ?role=agent&health>=1&unit=dcos-mesos*. String fields are matched with shell patterns,
+// comma separated values match any of the values. Results are sorted by ?sort=field, a field prefixed with - is
+// sorted in descending order. ?limit and ?offset select a page, the response total is the number of matched items.
+// Unknown parameters are rejected.
+type listQuery struct {
+	filters []queryFilter
+	sort    []sortKey
+	limit   int
+	offset  int
+}
+
+type queryFilter struct {
+	field    string
+	integer  bool
+	operator string
+	values   []string
+}
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// queryFields maps a query field name or its alias to a field name and reports whether the field is an integer.
+type queryFields map[string]struct {
+	name    string
+	integer bool
+}
+
+var (
+	unitQueryFields = queryFields{
+		"id":     {name: "id"},
+		"unit":   {name: "id"},
+		"name":   {name: "name"},
+		"health": {name: "health", integer: true},
+	}
+
+	nodeQueryFields = queryFields{
+		"host_ip": {name: "host_ip"},
+		"host":    {name: "host_ip"},
+		"role":    {name: "role"},
+		"health":  {name: "health", integer: true},
+	}
+
+	// query parameters used by other handlers and middlewares, and a cache buster ?_=<timestamp>.
+	reservedQueryParams = map[string]bool{
+		"cache":   true,
+		"wait":    true,
+		"timeout": true,
+		"format":  true,
+		"window":  true,
+		"_":       true,
+	}
+
+	queryParamRegexp = regexp.MustCompile(`^([a-z_]+)(>=|<=|!=|=|>|<)(.*)$`)
+)
+
+// queryable is an item of a list response which could be filtered and sorted.
+type queryable interface {
+	queryField(name string) (string, int)
+}
+
+// queryableUnit and queryableNode are the list items of the units and nodes responses.
+type queryableUnit unitResponseFieldsStruct
+
+type queryableNode nodeResponseFieldsStruct
+
+func (u queryableUnit) queryField(name string) (string, int) {
+	switch name {
+	case "id":
+		return u.UnitID, 0
+	case "name":
+		return u.PrettyName, 0
+	}
+	return "", u.UnitHealth
+}
+
+func (n *queryableNode) queryField(name string) (string, int) {
+	switch name {
+	case "host_ip":
+		return n.HostIP, 0
+	case "role":
+		return n.NodeRole, 0
+	}
+	return "", n.NodeHealth
+}
+
+// parseListQuery parses a raw URL query. The default sort field is used if the sort order is not requested.
+func parseListQuery(rawQuery string, fields queryFields, defaultSort string) (listQuery, error) {
+	q := listQuery{
+		sort: []sortKey{{field: defaultSort}},
+	}
+	if rawQuery == "" {
+		return q, nil
+	}
+
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		param, err := url.QueryUnescape(param)
+		if err != nil {
+			return q, invalidArgumentError("Incorrect query %s: %s", param, err)
+		}
+
+		match := queryParamRegexp.FindStringSubmatch(param)
+		if match == nil {
+			if reservedQueryParams[param] {
+				continue
+			}
+			return q, invalidArgumentError("Incorrect query parameter %s", param)
+		}
+		key, operator, value := match[1], match[2], match[3]
+
+		switch key {
+		case "sort":
+			if operator != "=" {
+				return q, invalidArgumentError("Incorrect query parameter %s", param)
+			}
+			q.sort = nil
+			for _, field := range strings.Split(value, ",") {
+				desc := strings.HasPrefix(field, "-")
+				f, ok := fields[strings.TrimPrefix(field, "-")]
+				if !ok {
+					return q, invalidArgumentError("Incorrect sort field %s", field)
+				}
+				q.sort = append(q.sort, sortKey{field: f.name, desc: desc})
+			}
+		case "limit", "offset":
+			n, err := strconv.Atoi(value)
+			if operator != "=" || err != nil || n < 0 {
+				return q, invalidArgumentError("%s must be a non-negative integer, got %s", key, param)
+			}
+			if key == "limit" {
+				q.limit = n
+			} else {
+				q.offset = n
+			}
+		default:
+			if reservedQueryParams[key] {
+				continue
+			}
+			f, ok := fields[key]
+			if !ok {
+				return q, invalidArgumentError("Incorrect filter field %s", key)
+			}
+			values := strings.Split(value, ",")
+			if f.integer {
+				for _, v := range values {
+					if _, err := strconv.Atoi(v); err != nil {
+						return q, invalidArgumentError("%s must be an integer, got %s", key, v)
+					}
+				}
+			} else {
+				if operator != "=" && operator != "!=" {
+					return q, invalidArgumentError("Operator %s is not supported for field %s", operator, key)
+				}
+				for _, v := range values {
+					if _, err := path.Match(v, ""); err != nil {
+						return q, invalidArgumentError("Incorrect %s pattern %s: %s", key, v, err)
+					}
+				}
+			}
+			q.filters = append(q.filters, queryFilter{
+				field:    f.name,
+				integer:  f.integer,
+				operator: operator,
+				values:   values,
+			})
+		}
+	}
+	return q, nil
+}
+
+// match returns true if an item matches all filters.
+func (q listQuery) match(item queryable) bool {
+	for _, f := range q.filters {
+		if !f.match(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f queryFilter) match(item queryable) bool {
+	s, n := item.queryField(f.field)
+
+	var matched bool
+	for _, value := range f.values {
+		var equal bool
+		if f.integer {
+			// values are validated by parseListQuery
+			v, _ := strconv.Atoi(value)
+			switch f.operator {
+			case ">":
+				equal = n > v
+			case ">=":
+				equal = n >= v
+			case "<":
+				equal = n < v
+			case "<=":
+				equal = n <= v
+			default:
+				equal = n == v
+			}
+		} else {
+			// patterns are validated by parseListQuery
+			equal, _ = path.Match(value, s)
+		}
+		matched = matched || equal
+	}
+
+	if f.operator == "!=" {
+		return !matched
+	}
+	return matched
+}
+
+// less compares two items by the sort keys.
+func (q listQuery) less(a, b queryable) bool {
+	for _, key := range q.sort {
+		as, an := a.queryField(key.field)
+		bs, bn := b.queryField(key.field)
+		if as == bs && an == bn {
+			continue
+		}
+		if key.desc {
+			return as > bs || (as == bs && an > bn)
+		}
+		return as < bs || (as == bs && an < bn)
+	}
+	return false
+}
+
+// page returns a start and an end index of the requested page in a list of n items.
+func (q listQuery) page(n int) (int, int) {
+	start := q.offset
+	if start > n {
+		start = n
+	}
+	end := n
+	if q.limit > 0 && start+q.limit < n {
+		end = start + q.limit
+	}
+	return start, end
+}
+
+// applyToUnits returns the requested page of the matched and sorted units and the number of matched units.
+func (q listQuery) applyToUnits(units []unitResponseFieldsStruct) ([]unitResponseFieldsStruct, int) {
+	result := []unitResponseFieldsStruct{}
+	for _, u := range units {
+		if q.match(queryableUnit(u)) {
+			result = append(result, u)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return q.less(queryableUnit(result[i]), queryableUnit(result[j]))
+	})
+	start, end := q.page(len(result))
+	return result[start:end], len(result)
+}
+
+// applyToNodes returns the requested page of the matched and sorted nodes and the number of matched nodes.
+func (q listQuery) applyToNodes(nodes []*nodeResponseFieldsStruct) ([]*nodeResponseFieldsStruct, int) {
+	result := []*nodeResponseFieldsStruct{}
+	for _, n := range nodes {
+		if q.match((*queryableNode)(n)) {
+			result = append(result, n)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return q.less((*queryableNode)(result[i]), (*queryableNode)(result[j]))
+	})
+	start, end := q.page(len(result))
+	return result[start:end], len(result)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// refreshAll is a refresh target to pull all cluster nodes.
+const refreshAll = "all"
+
+// PullRefresher coalesces cluster health refresh requests. Concurrent requests for the same target share
+// a single in-flight pull. A full refresh excludes the targeted refreshes, so a full pull started before a targeted
+// refresh does not overwrite the fresher nodes of the targeted refresh when it publishes last.
+type PullRefresher struct {
+	sync.Mutex
+	inflight map[string]chan struct{}
+
+	// writers is held exclusively by a full refresh and shared by the targeted refreshes.
+	writers sync.RWMutex
+}
+
+// NewPullRefresher returns a new instance of PullRefresher.
+func NewPullRefresher() *PullRefresher {
+	return &PullRefresher{
+		inflight: make(map[string]chan struct{}),
+	}
+}
+
+// refresh runs fn in a separate goroutine unless a refresh for the same target is already in flight.
+// The returned channel is closed when the refresh is done.
+func (p *PullRefresher) refresh(target string, fn func()) <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+	if done, ok := p.inflight[target]; ok {
+		logrus.Debugf("Refresh of %s is in flight, waiting for it", target)
+		return done
+	}
+
+	done := make(chan struct{})
+	p.inflight[target] = done
+	go func() {
+		if target == refreshAll {
+			p.writers.Lock()
+			fn()
+			p.writers.Unlock()
+		} else {
+			p.writers.RLock()
+			fn()
+			p.writers.RUnlock()
+		}
+		p.Lock()
+		delete(p.inflight, target)
+		p.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// refreshNodes pulls the given nodes and updates them in globalMonitoringResponse.
+func refreshNodes(nodes []Node, dt Dt) {
+	respChan := make(chan *httpResponse, len(nodes))
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		// reset the previously pulled state, the node will be pulled again.
+		node.Health = 0
+		node.Units = nil
+		node.Output = nil
+		node.System = nil
+		node.DCOSVersion = ""
+		node.TDTVersion = ""
+		node.NegotiatedScheme = ""
+
+		wg.Add(1)
+		go pullHostStatus(node, respChan, dt, &wg)
+	}
+	wg.Wait()
+	close(respChan)
+
+	var responses []*httpResponse
+	for response := range respChan {
+		responses = append(responses, response)
+	}
+	globalMonitoringResponse.updateNodes(responses, dt.Cfg.AggregationPolicies)
+	dt.DtNodeTracker.update()
+}
+
+// refreshTarget returns a refresh target and a function to refresh it for a request. A request to a specific node
+// refreshes the node only, a request to a specific unit refreshes the nodes the unit is running on. All other
+// requests and requests for unknown nodes or units refresh the entire cluster.
+func refreshTarget(vars map[string]string, dt Dt) (string, func()) {
+	var nodeIPs []string
+	target := refreshAll
+	if nodeID, ok := vars["nodeid"]; ok {
+		// v2 routes address nodes by Mesos ID or hostname too.
+		if ip, ok := globalMonitoringResponse.resolveNodeIP(nodeID); ok {
+			nodeID = ip
+		}
+		nodeIPs = []string{nodeID}
+		target = "node:" + nodeID
+	} else if unitID, ok := vars["unitid"]; ok {
+		if ips, ok := globalMonitoringResponse.getUnitNodeIPs(unitID); ok {
+			nodeIPs = ips
+			target = "unit:" + unitID
+		}
+	}
+
+	if len(nodeIPs) > 0 {
+		if nodes, ok := globalMonitoringResponse.getNodesByIP(nodeIPs); ok {
+			return target, func() {
+				refreshNodes(nodes, dt)
+			}
+		}
+		logrus.Debugf("Could not find nodes %s, refreshing the entire cluster", nodeIPs)
+	}
+
+	return refreshAll, func() {
+		// the cluster topology may have changed, find the nodes again.
+		dt.DtDCOSTools.InvalidateNodesCache()
+		runPull(dt)
+	}
+}
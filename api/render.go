@@ -0,0 +1,362 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Report formats requested with ?format=, JSON is the default.
+const (
+	formatJSON     = "json"
+	formatHTML     = "html"
+	formatMarkdown = "markdown"
+	formatCSV      = "csv"
+)
+
+var formatContentTypes = map[string]string{
+	formatHTML:     "text/html; charset=utf-8",
+	formatMarkdown: "text/markdown; charset=utf-8",
+	formatCSV:      "text/csv; charset=utf-8",
+}
+
+var formatFileExtensions = map[string]string{
+	formatHTML:     "html",
+	formatMarkdown: "md",
+	formatCSV:      "csv",
+}
+
+// requestFormat returns a format requested with ?format=.
+func requestFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" || format == formatJSON {
+		return formatJSON, nil
+	}
+	if _, ok := formatContentTypes[format]; !ok {
+		return "", invalidArgumentError("Incorrect format %s, must be: %s, %s, %s or %s", format, formatJSON,
+			formatHTML, formatMarkdown, formatCSV)
+	}
+	return format, nil
+}
+
+// reportView is a health report rendered for people. The units embed the unit health on every node, the nodes
+// embed their units.
+type reportView struct {
+	Title       string
+	UpdatedTime time.Time
+	Units       []unitV2
+	Nodes       []nodeV2
+}
+
+// nodeFailures is a node with its unhealthy units.
+type nodeFailures struct {
+	nodeRefV2
+	Units []unitOnNodeV2
+}
+
+// newReportView returns a view of the entire health report. The units and nodes are taken from the same snapshot.
+func (mr *monitoringResponse) newReportView() reportView {
+	mr.RLock()
+	defer mr.RUnlock()
+	return reportView{
+		Title:       "DC/OS health report",
+		UpdatedTime: mr.UpdatedTime,
+		Units:       mr.unitsV2(),
+		Nodes:       mr.nodesV2(),
+	}
+}
+
+// newUnitsView returns a view of the units in the given order.
+func (mr *monitoringResponse) newUnitsView(units []unitResponseFieldsStruct) reportView {
+	view := mr.newReportView()
+	byID := make(map[string]unitV2)
+	for _, u := range view.Units {
+		byID[u.ID] = u
+	}
+	view.Title = "DC/OS units health"
+	view.Units = []unitV2{}
+	for _, u := range units {
+		if found, ok := byID[u.UnitID]; ok {
+			view.Units = append(view.Units, found)
+		}
+	}
+	view.Nodes = nil
+	return view
+}
+
+// newNodesView returns a view of the nodes in the given order.
+func (mr *monitoringResponse) newNodesView(nodes []*nodeResponseFieldsStruct) reportView {
+	view := mr.newReportView()
+	byIP := make(map[string]nodeV2)
+	for _, node := range view.Nodes {
+		byIP[node.IP] = node
+	}
+	view.Title = "DC/OS nodes health"
+	view.Units = nil
+	view.Nodes = []nodeV2{}
+	for _, node := range nodes {
+		if found, ok := byIP[node.HostIP]; ok {
+			view.Nodes = append(view.Nodes, found)
+		}
+	}
+	return view
+}
+
+// rows returns the health of every unit on every node. The rows are taken from the units if the view has units,
+// from the nodes otherwise.
+func (v reportView) rows() []unitOnNodeV2 {
+	var rows []unitOnNodeV2
+	if v.Units != nil {
+		for _, u := range v.Units {
+			rows = append(rows, u.Nodes...)
+		}
+		return rows
+	}
+	for _, node := range v.Nodes {
+		rows = append(rows, node.Units...)
+	}
+	return rows
+}
+
+// FailingNodes returns the nodes with unhealthy units, sorted by IP address.
+func (v reportView) FailingNodes() []nodeFailures {
+	byIP := make(map[string]*nodeFailures)
+	for _, row := range v.rows() {
+		if row.Health == 0 {
+			continue
+		}
+		failures, ok := byIP[row.IP]
+		if !ok {
+			failures = &nodeFailures{nodeRefV2: row.nodeRefV2}
+			byIP[row.IP] = failures
+		}
+		failures.Units = append(failures.Units, row)
+	}
+
+	result := []nodeFailures{}
+	for _, failures := range byIP {
+		sort.Slice(failures.Units, func(i, j int) bool {
+			return failures.Units[i].UnitID < failures.Units[j].UnitID
+		})
+		result = append(result, *failures)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
+
+// Updated returns the updated time of the report or "never".
+func (v reportView) Updated() string {
+	if v.UpdatedTime.IsZero() {
+		return "never"
+	}
+	return v.UpdatedTime.UTC().Format(time.RFC1123)
+}
+
+// healthText returns a health status for people.
+func healthText(health int) string {
+	if health == 0 {
+		return "Healthy"
+	}
+	return "Unhealthy"
+}
+
+// unhealthyNodes returns the number of nodes a unit is unhealthy on.
+func unhealthyNodes(u unitV2) int {
+	var n int
+	for _, node := range u.Nodes {
+		if node.Health != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// unhealthyUnits returns the number of unhealthy units of a node.
+func unhealthyUnits(node nodeV2) int {
+	var n int
+	for _, u := range node.Units {
+		if u.Health != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// writeView writes a view in the format. A download gets a file name with the format extension.
+func writeView(w http.ResponseWriter, view reportView, format string) {
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	if w.Header().Get("Content-disposition") != "" {
+		w.Header().Set("Content-disposition", "attachment; filename=health-report."+formatFileExtensions[format])
+	}
+
+	var err error
+	switch format {
+	case formatHTML:
+		err = htmlReportTemplate.Execute(w, view)
+	case formatMarkdown:
+		err = writeMarkdown(w, view)
+	case formatCSV:
+		err = writeCSV(w, view)
+	}
+	if err != nil {
+		log.Errorf("Failed to render a report as %s: %s", format, err)
+	}
+}
+
+// writeCSV writes one row per unit per node.
+func writeCSV(w io.Writer, view reportView) error {
+	writer := csv.NewWriter(w)
+	header := []string{"unit_id", "unit_name", "node_ip", "hostname", "mesos_id", "role", "leader", "health",
+		"output", "timestamp", "help"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range view.rows() {
+		record := []string{row.UnitID, row.Name, row.IP, row.Hostname, row.MesosID, row.Role,
+			strconv.FormatBool(row.Leader), strconv.Itoa(row.Health), row.Output, row.Timestamp.UTC().Format(time.RFC3339),
+			row.Help}
+		for i, cell := range record {
+			record[i] = csvCell(cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell prefixes a cell which a spreadsheet would evaluate as a formula with a quote, e.g. a unit output starting
+// with =.
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+var markdownCellEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+
+// writeMarkdown writes the failing units grouped by node with their outputs and the units and nodes tables.
+func writeMarkdown(w io.Writer, view reportView) error {
+	var b strings.Builder
+	cell := markdownCellEscaper.Replace
+	fmt.Fprintf(&b, "# %s\n\nUpdated: %s\n\n", view.Title, view.Updated())
+
+	b.WriteString("## Failing units by node\n\n")
+	failing := view.FailingNodes()
+	if len(failing) == 0 {
+		b.WriteString("All units are healthy.\n\n")
+	}
+	for _, node := range failing {
+		fmt.Fprintf(&b, "### %s (%s, %s)\n\n", node.IP, cell(node.Hostname), node.Role)
+		for _, u := range node.Units {
+			fmt.Fprintf(&b, "#### %s (%s)\n\n", u.UnitID, cell(u.Name))
+			if u.Output != "" {
+				// the fence is longer than any backtick run in the output.
+				fence := "```"
+				for strings.Contains(u.Output, fence) {
+					fence += "`"
+				}
+				fmt.Fprintf(&b, "%s\n%s\n%s\n\n", fence, strings.TrimRight(u.Output, "\n"), fence)
+			}
+			if u.Help != "" {
+				// a line break in markdown is two trailing spaces.
+				fmt.Fprintf(&b, "%s\n\n", strings.Replace(u.Help, "\n", "  \n", -1))
+			}
+		}
+	}
+
+	if view.Units != nil {
+		b.WriteString("## Units\n\n| Unit | Name | Health | Unhealthy nodes | Description |\n|---|---|---|---|---|\n")
+		for _, u := range view.Units {
+			fmt.Fprintf(&b, "| %s | %s | %s | %d/%d | %s |\n", cell(u.ID), cell(u.Name), healthText(u.Health),
+				unhealthyNodes(u), len(u.Nodes), cell(u.Title))
+		}
+		b.WriteString("\n")
+	}
+	if view.Nodes != nil {
+		b.WriteString("## Nodes\n\n| IP | Hostname | Role | Health | Unhealthy units |\n|---|---|---|---|---|\n")
+		for _, node := range view.Nodes {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %d/%d |\n", node.IP, cell(node.Hostname), node.Role,
+				healthText(node.Health), unhealthyUnits(node), len(node.Units))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// htmlReportTemplate is a self-contained HTML report, it does not load any resources.
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"health":         healthText,
+	"unhealthyNodes": unhealthyNodes,
+	"unhealthyUnits": unhealthyUnits,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f0f0f0; }
+pre { background: #f7f7f7; border: 1px solid #ddd; padding: 0.6em; white-space: pre-wrap; }
+.help { white-space: pre-wrap; }
+.healthy { color: #1a7f37; }
+.unhealthy { color: #cf222e; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Updated: {{.Updated}}</p>
+
+<h2>Failing units by node</h2>
+{{- range .FailingNodes}}
+<h3>{{.IP}} ({{.Hostname}}, {{.Role}}{{if .Leader}}, leader{{end}})</h3>
+{{- range .Units}}
+<h4>{{.UnitID}} ({{.Name}}) <span class="unhealthy">{{health .Health}}</span></h4>
+{{- if .Output}}
+<pre>{{.Output}}</pre>
+{{- end}}
+{{- if .Help}}
+<p class="help">{{.Help}}</p>
+{{- end}}
+{{- end}}
+{{- else}}
+<p class="healthy">All units are healthy.</p>
+{{- end}}
+{{- if .Units}}
+
+<h2>Units</h2>
+<table>
+<tr><th>Unit</th><th>Name</th><th>Health</th><th>Unhealthy nodes</th><th>Description</th></tr>
+{{- range .Units}}
+<tr><td>{{.ID}}</td><td>{{.Name}}</td><td class="{{if .Health}}unhealthy{{else}}healthy{{end}}">{{health .Health}}</td><td>{{unhealthyNodes .}}/{{len .Nodes}}</td><td>{{.Title}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Nodes}}
+
+<h2>Nodes</h2>
+<table>
+<tr><th>IP</th><th>Hostname</th><th>Role</th><th>Health</th><th>Unhealthy units</th></tr>
+{{- range .Nodes}}
+<tr><td>{{.IP}}</td><td>{{.Hostname}}</td><td>{{.Role}}</td><td class="{{if .Health}}unhealthy{{else}}healthy{{end}}">{{health .Health}}</td><td>{{unhealthyUnits .}}/{{len .Units}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
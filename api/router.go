@@ -14,12 +14,18 @@ import (
 // BaseRoute a base 3dt endpoint location.
 const BaseRoute string = "/system/health/v1"
 
+// BaseRouteV2 a base location of the v2 health API, nodes are addressed by Mesos ID, hostname or IP address.
+const BaseRouteV2 string = "/system/health/v2"
+
 type routeHandler struct {
 	url                 string
 	handler             func(http.ResponseWriter, *http.Request)
 	headers             []header
 	methods             []string
 	gzip, canFlushCache bool
+
+	// versioned routes serve the health report snapshot, they support conditional requests and long-polling.
+	versioned bool
 }
 
 type header struct {
@@ -54,20 +60,26 @@ func noCacheMiddleware(next http.Handler, dt Dt) http.Handler {
 			return
 		}
 
-		if !dt.Cfg.FlagPull {
-			e := "3dt was not started with -pull flag"
-			logrus.Error(e)
-			http.Error(w, e, http.StatusServiceUnavailable)
+		if !dt.Cfg.FlagPull || dt.PullRefresher == nil {
+			httpError(w, "3dt was not started with -pull flag", http.StatusServiceUnavailable)
 			return
 		}
 
-		dt.RunPullerChan <- true
+		// concurrent requests to refresh the same target wait for the same pull.
+		target, refreshFn := refreshTarget(mux.Vars(r), dt)
 		select {
-		case <-dt.RunPullerDoneChan:
-			logrus.Debug("Fresh data updated")
+		case <-dt.PullRefresher.refresh(target, refreshFn):
+			logrus.Debugf("Fresh data updated, target: %s", target)
+
+		case <-r.Context().Done():
+			// the refresh keeps running for other requests waiting for it.
+			logrus.Debugf("Refresh request canceled, target %s: %s", target, r.Context().Err())
+			httpError(w, "Refresh request canceled: "+r.Context().Err().Error(), http.StatusGatewayTimeout)
+			return
 
 		case <-time.After(time.Minute):
-			panic("Error getting fresh health report")
+			httpError(w, "Timeout getting a fresh health report, target: "+target, http.StatusGatewayTimeout)
+			return
 		}
 
 		if t := globalMonitoringResponse.GetLastUpdatedTime(); t != "" {
@@ -91,6 +103,7 @@ func getRoutes(dt Dt) []routeHandler {
 			url:           fmt.Sprintf("%s/report", BaseRoute),
 			handler:       reportHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/report/download
@@ -103,54 +116,134 @@ func getRoutes(dt Dt) []routeHandler {
 				},
 			},
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/units
 			url:           fmt.Sprintf("%s/units", BaseRoute),
 			handler:       getAllUnitsHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/units/<unitid>
 			url:           fmt.Sprintf("%s/units/{unitid}", BaseRoute),
 			handler:       getUnitByIDHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/units/<unitid>/nodes
 			url:           fmt.Sprintf("%s/units/{unitid}/nodes", BaseRoute),
 			handler:       getNodesByUnitIDHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/units/<unitid>/nodes/<nodeid>
 			url:           fmt.Sprintf("%s/units/{unitid}/nodes/{nodeid}", BaseRoute),
 			handler:       getNodeByUnitIDNodeIDHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/nodes
 			url:           fmt.Sprintf("%s/nodes", BaseRoute),
 			handler:       getNodesHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/nodes/<nodeid>
 			url:           fmt.Sprintf("%s/nodes/{nodeid}", BaseRoute),
 			handler:       getNodeByIDHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/nodes/<nodeid>/units
 			url:           fmt.Sprintf("%s/nodes/{nodeid}/units", BaseRoute),
 			handler:       getNodeUnitsByNodeIDHandler,
 			canFlushCache: true,
+			versioned:     true,
 		},
 		{
 			// /system/health/v1/nodes/<nodeid>/units/<unitid>
 			url:           fmt.Sprintf("%s/nodes/{nodeid}/units/{unitid}", BaseRoute),
 			handler:       getNodeUnitByNodeIDUnitIDHandler,
 			canFlushCache: true,
+			versioned:     true,
+		},
+
+		{
+			// DELETE /system/health/v1/nodes/<nodeid>
+			url: fmt.Sprintf("%s/nodes/{nodeid}", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				forgetNodeHandler(w, r, dt)
+			},
+			methods: []string{"DELETE"},
+		},
+		{
+			// /system/health/v1/known-nodes
+			url: fmt.Sprintf("%s/known-nodes", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getKnownNodesHandler(w, r, dt)
+			},
+		},
+
+		{
+			// /system/health/v1/discovery
+			url: fmt.Sprintf("%s/discovery", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				discoveryHandler(w, r, dt)
+			},
+		},
+		{
+			// /system/health/v1/versions
+			url:           fmt.Sprintf("%s/versions", BaseRoute),
+			handler:       getVersionsHandler,
+			canFlushCache: true,
+		},
+		{
+			// /system/health/v1/nodes/<nodeid>/system
+			url:           fmt.Sprintf("%s/nodes/{nodeid}/system", BaseRoute),
+			handler:       getNodeSystemHandler,
+			canFlushCache: true,
+		},
+		{
+			// /system/health/v1/system/top
+			url:           fmt.Sprintf("%s/system/top", BaseRoute),
+			handler:       getSystemTopHandler,
+			canFlushCache: true,
+		},
+		{
+			// /system/health/v1/units/<unitid>/availability
+			url: fmt.Sprintf("%s/units/{unitid}/availability", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getUnitAvailabilityHandler(w, r, dt)
+			},
+		},
+		{
+			// /system/health/v1/nodes/<nodeid>/availability
+			url: fmt.Sprintf("%s/nodes/{nodeid}/availability", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getNodeAvailabilityHandler(w, r, dt)
+			},
+		},
+		{
+			// /system/health/v1/availability
+			url: fmt.Sprintf("%s/availability", BaseRoute),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getAvailabilityHandler(w, r, dt)
+			},
+		},
+		{
+			// /system/health/v1/push
+			url: BaseRoute + "/push",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				pushHandler(w, r, dt)
+			},
+			methods: []string{"POST"},
 		},
 
 		// diagnostics routes
@@ -175,6 +268,20 @@ func getRoutes(dt Dt) []routeHandler {
 			},
 			gzip: true,
 		},
+		{
+			// /system/health/v1/nodes/<nodeid>/logs
+			url: BaseRoute + "/nodes/{nodeid}/logs",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				nodeLogsListHandler(w, r, dt)
+			},
+		},
+		{
+			// /system/health/v1/nodes/<nodeid>/logs/<provider>/<entity>
+			url: BaseRoute + "/nodes/{nodeid}/logs/{provider}/{entity}",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				nodeLogHandler(w, r, dt)
+			},
+		},
 		{
 			// /system/health/v1/report/diagnostics
 			url: BaseRoute + "/report/diagnostics/create",
@@ -237,6 +344,28 @@ func getRoutes(dt Dt) []routeHandler {
 			},
 			methods: []string{"POST"},
 		},
+		{
+			// /system/health/v1/summary
+			url: BaseRoute + "/summary",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getSummaryHandler(w, r, dt)
+			},
+			methods:       []string{"GET", "HEAD"},
+			canFlushCache: true,
+		},
+		{
+			// /system/health/v1/metrics
+			url: BaseRoute + "/metrics",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				metricsHandler(w, r, dt)
+			},
+			headers: []header{
+				{
+					name:  "Content-type",
+					value: metricsContentType,
+				},
+			},
+		},
 		// self test route
 		{
 			url:     BaseRoute + "/selftest/info",
@@ -244,6 +373,12 @@ func getRoutes(dt Dt) []routeHandler {
 		},
 	}
 
+	routes = append(routes, getV2Routes()...)
+
+	if dt.DtFederation != nil {
+		routes = append(routes, getFederationRoutes(dt)...)
+	}
+
 	if dt.Cfg.FlagDebug {
 		logrus.Debug("Enabling pprof endpoints.")
 		routes = append(routes, []routeHandler{
@@ -322,16 +457,161 @@ func getRoutes(dt Dt) []routeHandler {
 	return routes
 }
 
+func getV2Routes() []routeHandler {
+	return []routeHandler{
+		{
+			// /system/health/v2/units
+			url: BaseRouteV2 + "/units",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetUnitsV2(), nil
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+		{
+			// /system/health/v2/units/<unitid>
+			url: BaseRouteV2 + "/units/{unitid}",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetUnitV2(vars["unitid"])
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+		{
+			// /system/health/v2/units/<unitid>/nodes/<nodeid>
+			url: BaseRouteV2 + "/units/{unitid}/nodes/{nodeid}",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetUnitOnNodeV2(vars["nodeid"], vars["unitid"])
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+		{
+			// /system/health/v2/nodes
+			url: BaseRouteV2 + "/nodes",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetNodesV2(), nil
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+		{
+			// /system/health/v2/nodes/<nodeid>
+			url: BaseRouteV2 + "/nodes/{nodeid}",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetNodeV2(vars["nodeid"])
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+		{
+			// /system/health/v2/nodes/<nodeid>/units/<unitid>
+			url: BaseRouteV2 + "/nodes/{nodeid}/units/{unitid}",
+			handler: healthV2Handler(func(vars map[string]string) (interface{}, error) {
+				return globalMonitoringResponse.GetUnitOnNodeV2(vars["nodeid"], vars["unitid"])
+			}),
+			canFlushCache: true,
+			versioned:     true,
+		},
+	}
+}
+
+func getFederationRoutes(dt Dt) []routeHandler {
+	clusterRoute := FederationBaseRoute + "/clusters/{cluster}"
+	return []routeHandler{
+		{
+			// /federation/v1/clusters
+			url: FederationBaseRoute + "/clusters",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getFederatedClustersHandler(w, r, dt)
+			},
+		},
+		{
+			// /federation/v1/units
+			url: FederationBaseRoute + "/units",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				getFederatedUnitsHandler(w, r, dt)
+			},
+		},
+		{
+			// /federation/v1/clusters/<cluster>/report
+			url: clusterRoute + "/report",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report, nil
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/units
+			url: clusterRoute + "/units",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetAllUnits(), nil
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/units/<unitid>
+			url: clusterRoute + "/units/{unitid}",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetUnit(vars["unitid"])
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/units/<unitid>/nodes
+			url: clusterRoute + "/units/{unitid}/nodes",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetNodesForUnit(vars["unitid"])
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/units/<unitid>/nodes/<nodeid>
+			url: clusterRoute + "/units/{unitid}/nodes/{nodeid}",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetSpecificNodeForUnit(vars["unitid"], vars["nodeid"])
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/nodes
+			url: clusterRoute + "/nodes",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetNodes(), nil
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/nodes/<nodeid>
+			url: clusterRoute + "/nodes/{nodeid}",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetNodeByID(vars["nodeid"])
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/nodes/<nodeid>/units
+			url: clusterRoute + "/nodes/{nodeid}/units",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetNodeUnitsID(vars["nodeid"])
+			}),
+		},
+		{
+			// /federation/v1/clusters/<cluster>/nodes/<nodeid>/units/<unitid>
+			url: clusterRoute + "/nodes/{nodeid}/units/{unitid}",
+			handler: federatedClusterHandler(dt, func(report *monitoringResponse, vars map[string]string) (interface{}, error) {
+				return report.GetNodeUnitByNodeIDUnitID(vars["nodeid"], vars["unitid"])
+			}),
+		},
+	}
+}
+
 func wrapHandler(handler http.Handler, route routeHandler, dt Dt) http.Handler {
 	h := headerMiddleware(handler, route.headers)
 	if route.gzip {
 		h = handlers.CompressHandler(h)
 	}
+	if route.versioned {
+		h = snapshotMiddleware(h)
+	}
 	if route.canFlushCache {
 		h = noCacheMiddleware(h, dt)
 	}
 
-	return h
+	return requestIDMiddleware(h)
 }
 
 func loadRoutes(router *mux.Router, dt Dt) *mux.Router {
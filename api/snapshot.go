@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// snapshotVersionHeader is a header with the version of the health report snapshot a response was made from. The
+// version is used to long-poll for a newer snapshot with ?wait=<version>.
+const snapshotVersionHeader = "X-Snapshot-Version"
+
+const (
+	// defaultWaitTimeout is used if a long-poll request does not set a timeout.
+	defaultWaitTimeout = 30 * time.Second
+
+	// maxWaitTimeout is the longest timeout of a long-poll request.
+	maxWaitTimeout = 5 * time.Minute
+)
+
+// publish increments the snapshot version and wakes up the requests waiting for a newer snapshot if the content of
+// the health report changed. The caller must hold the lock.
+func (mr *monitoringResponse) publish() {
+	hash, err := mr.contentHash()
+	if err != nil {
+		logrus.Errorf("Could not hash the health report, publishing a new snapshot: %s", err)
+	} else if mr.version > 0 && hash == mr.hash {
+		return
+	}
+
+	mr.hash = hash
+	mr.modified = mr.UpdatedTime
+	mr.version++
+	if mr.changed != nil {
+		close(mr.changed)
+	}
+	mr.changed = make(chan struct{})
+}
+
+// contentHash returns a hash of the units and nodes. The timestamps and the system metrics change on every pull,
+// they are not a part of the content. The caller must hold the lock.
+func (mr *monitoringResponse) contentHash() ([sha256.Size]byte, error) {
+	units := make(map[string]unit, len(mr.Units))
+	for name, u := range mr.Units {
+		units[name] = stableUnit(u)
+	}
+	nodes := make(map[string]Node, len(mr.Nodes))
+	for ip, node := range mr.Nodes {
+		nodes[ip] = stableNode(node)
+	}
+	content, err := json.Marshal(monitoringResponse{Units: units, Nodes: nodes})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(content), nil
+}
+
+// stableUnit returns a copy of a unit without the fields changed on every pull.
+func stableUnit(u unit) unit {
+	u.Timestamp = time.Time{}
+	if u.Nodes != nil {
+		nodes := make([]Node, len(u.Nodes))
+		for i, node := range u.Nodes {
+			nodes[i] = stableNode(node)
+		}
+		u.Nodes = nodes
+	}
+	return u
+}
+
+// stableNode returns a copy of a node without the fields changed on every pull.
+func stableNode(node Node) Node {
+	node.System = nil
+	if node.Units != nil {
+		units := make([]unit, len(node.Units))
+		for i, u := range node.Units {
+			units[i] = stableUnit(u)
+		}
+		node.Units = units
+	}
+	return node
+}
+
+// snapshot returns the current snapshot version, the time its content was modified and a channel closed when
+// a newer snapshot is published.
+func (mr *monitoringResponse) snapshot() (uint64, time.Time, <-chan struct{}) {
+	mr.RLock()
+	defer mr.RUnlock()
+	mr.changedOnce.Do(func() {
+		// publish sets the channel under the write lock, it is created here only before the first snapshot.
+		if mr.changed == nil {
+			mr.changed = make(chan struct{})
+		}
+	})
+	return mr.version, mr.modified, mr.changed
+}
+
+// snapshotETag returns a weak entity tag of a snapshot. The timestamps and the system metrics of the responses could
+// differ within a snapshot. The modified time distinguishes snapshots of different 3dt runs.
+func snapshotETag(version uint64, modified time.Time) string {
+	return fmt.Sprintf(`W/"%d-%d"`, version, modified.UnixNano())
+}
+
+// notModified reports whether a conditional request matches the current snapshot. If-None-Match takes precedence
+// over If-Modified-Since.
+func notModified(r *http.Request, etag string, updated time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// If-None-Match uses the weak comparison.
+		etag = strings.TrimPrefix(etag, "W/")
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || updated.IsZero() {
+		return false
+	}
+	// Last-Modified has a precision of one second.
+	return !updated.Truncate(time.Second).After(ims)
+}
+
+// parseWaitTimeout parses a long-poll timeout in seconds or as a duration, e.g. 30 or 30s.
+func parseWaitTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultWaitTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(s)
+		if atoiErr != nil {
+			return 0, invalidArgumentError("timeout must be a number of seconds or a duration, got %s", s)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout <= 0 || timeout > maxWaitTimeout {
+		return 0, invalidArgumentError("timeout must be positive and at most %s, got %s", maxWaitTimeout, s)
+	}
+	return timeout, nil
+}
+
+// waitForSnapshot waits until a snapshot newer than the version is published. It returns false if the timeout
+// expires or the request is canceled first. A version other than the current one, e.g. from before a 3dt restart,
+// does not wait.
+func waitForSnapshot(r *http.Request, version uint64, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		current, _, changed := globalMonitoringResponse.snapshot()
+		if current != version {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+// snapshotMiddleware makes the responses of the health report snapshot cacheable with ETag and Last-Modified and
+// responds with 304 Not Modified to the matching conditional requests. A request with ?wait=<version> is a long-poll,
+// it is served as soon as a snapshot newer than the version is published, or with 304 if the timeout expires first.
+func snapshotMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if wait := query.Get("wait"); wait != "" {
+			version, err := strconv.ParseUint(wait, 10, 64)
+			if err != nil {
+				writeError(w, invalidArgumentError("wait must be a snapshot version, got %s", wait))
+				return
+			}
+			timeout, err := parseWaitTimeout(query.Get("timeout"))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if !waitForSnapshot(r, version, timeout) {
+				if r.Context().Err() != nil {
+					return
+				}
+				setSnapshotHeaders(w)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		etag, updated := setSnapshotHeaders(w)
+		if notModified(r, etag, updated) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setSnapshotHeaders sets the ETag, Last-Modified and snapshot version headers of the current snapshot.
+func setSnapshotHeaders(w http.ResponseWriter) (string, time.Time) {
+	version, updated, _ := globalMonitoringResponse.snapshot()
+	etag := snapshotETag(version, updated)
+	w.Header().Set("ETag", etag)
+	w.Header().Set(snapshotVersionHeader, strconv.FormatUint(version, 10))
+	if !updated.IsZero() {
+		w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+	return etag, updated
+}
@@ -1,12 +1,11 @@
 package api
 
 import (
+	"crypto/sha256"
 	"sync"
 	"time"
 
-	"github.com/shirou/gopsutil/disk"
-	"github.com/shirou/gopsutil/load"
-	"github.com/shirou/gopsutil/mem"
+	"github.com/dcos/3dt/client"
 )
 
 // MonitoringResponse top level global variable to store the entire units/nodes status tree.
@@ -16,29 +15,21 @@ type monitoringResponse struct {
 	Units       map[string]unit
 	Nodes       map[string]Node
 	UpdatedTime time.Time
+
+	// version is incremented when a snapshot with new content is published, changed is closed then. modified is the
+	// updated time of the snapshot which changed the content, hash is a hash of the content.
+	version     uint64
+	modified    time.Time
+	hash        [sha256.Size]byte
+	changed     chan struct{}
+	changedOnce sync.Once
 }
 
 // Unit for systemd unit.
-type unit struct {
-	UnitName   string
-	Nodes      []Node `json:",omitempty"`
-	Health     int
-	Title      string
-	Timestamp  time.Time
-	PrettyName string
-}
+type unit = client.Unit
 
 // Node for DC/OS node
-type Node struct {
-	Leader  bool
-	Role    string
-	IP      string
-	Host    string
-	Health  int
-	Output  map[string]string
-	Units   []unit `json:",omitempty"`
-	MesosID string
-}
+type Node = client.Node
 
 // HttpResponse a structure of http response from a remote host.
 type httpResponse struct {
@@ -48,74 +39,59 @@ type httpResponse struct {
 }
 
 // UnitsHealthResponseJSONStruct json response /system/health/v1
-type UnitsHealthResponseJSONStruct struct {
-	Array       []healthResponseValues `json:"units"`
-	System      sysMetrics             `json:"system"`
-	Hostname    string                 `json:"hostname"`
-	IPAddress   string                 `json:"ip"`
-	DcosVersion string                 `json:"dcos_version"`
-	Role        string                 `json:"node_role"`
-	MesosID     string                 `json:"mesos_id"`
-	TdtVersion  string                 `json:"3dt_version"`
-}
+type UnitsHealthResponseJSONStruct = client.HealthResponse
 
-type healthResponseValues struct {
-	UnitID     string `json:"id"`
-	UnitHealth int    `json:"health"`
-	UnitOutput string `json:"output"`
-	UnitTitle  string `json:"description"`
-	Help       string `json:"help"`
-	PrettyName string `json:"name"`
-}
+type healthResponseValues = client.UnitHealth
 
-type sysMetrics struct {
-	Memory      mem.VirtualMemoryStat `json:"memory"`
-	LoadAvarage load.AvgStat          `json:"load_avarage"`
-	Partitions  []disk.PartitionStat  `json:"partitions"`
-	DiskUsage   []disk.UsageStat      `json:"disk_usage"`
-}
+type sysMetrics = client.SysMetrics
 
 // unit health overview, collected from all hosts
-type unitsResponseJSONStruct struct {
-	Array []unitResponseFieldsStruct `json:"units"`
-}
+type unitsResponseJSONStruct = client.UnitsResponse
 
-type unitResponseFieldsStruct struct {
-	UnitID     string `json:"id"`
-	PrettyName string `json:"name"`
-	UnitHealth int    `json:"health"`
-	UnitTitle  string `json:"description"`
-}
+type unitResponseFieldsStruct = client.UnitResponse
 
 // nodes response
-type nodesResponseJSONStruct struct {
-	Array []*nodeResponseFieldsStruct `json:"nodes"`
-}
+type nodesResponseJSONStruct = client.NodesResponse
 
-type nodeResponseFieldsStruct struct {
-	HostIP     string `json:"host_ip"`
-	NodeHealth int    `json:"health"`
-	NodeRole   string `json:"role"`
+type nodeResponseFieldsStruct = client.NodeResponse
+
+// system metrics response
+type nodeSystemResponseJSONStruct = client.NodeSystemResponse
+
+// top nodes by resource usage
+type systemTopResponseJSONStruct struct {
+	Disk   []nodeUsageResponseFieldsStruct `json:"disk"`
+	Memory []nodeUsageResponseFieldsStruct `json:"memory"`
+	Load   []nodeUsageResponseFieldsStruct `json:"load"`
 }
 
-type nodeResponseFieldsWithErrorStruct struct {
-	HostIP     string `json:"host_ip"`
-	NodeHealth int    `json:"health"`
-	NodeRole   string `json:"role"`
-	UnitOutput string `json:"output"`
-	Help       string `json:"help"`
+type nodeUsageResponseFieldsStruct struct {
+	HostIP string  `json:"host_ip"`
+	Role   string  `json:"role"`
+	Value  float64 `json:"value"`
+	Path   string  `json:"path,omitempty"`
 }
 
+type nodeResponseFieldsWithErrorStruct = client.NodeUnitResponse
+
 // Agent response json format
 type agentsResponse struct {
-	Agents []struct {
-		Hostname   string `json:"hostname"`
-		Attributes struct {
-			PublicIP string `json:"public_ip"`
-		} `json:"attributes"`
-	} `json:"slaves"`
+	Agents []mesosAgent `json:"slaves"`
+}
+
+// mesosAgent is an agent returned by mesos /slaves endpoint. Attribute values are strings, numbers or ranges.
+type mesosAgent struct {
+	ID             string                 `json:"id"`
+	Hostname       string                 `json:"hostname"`
+	RegisteredTime float64                `json:"registered_time"`
+	Active         bool                   `json:"active"`
+	Resources      map[string]interface{} `json:"resources"`
+	Attributes     map[string]interface{} `json:"attributes"`
 }
 
+// mesosAgentInfo is an agent metadata found by the discovery.
+type mesosAgentInfo = client.MesosAgentInfo
+
 type exhibitorNodeResponse struct {
 	Code        int
 	Description string
@@ -126,18 +102,18 @@ type exhibitorNodeResponse struct {
 // Dt is a struct of dependencies used in 3dt code. There are 2 implementations, the one runs on a real system and
 // the one used for testing.
 type Dt struct {
-	Cfg               *Config
-	DtDCOSTools       DCOSHelper
-	DtDiagnosticsJob  *DiagnosticsJob
-	RunPullerChan     chan bool
-	RunPullerDoneChan chan bool
-	SystemdUnits      *SystemdUnits
-}
-
-type bundle struct {
-	File string `json:"file_name"`
-	Size int64  `json:"file_size"`
-}
+	Cfg              *Config
+	DtDCOSTools      DCOSHelper
+	DtDiagnosticsJob *DiagnosticsJob
+	DtFederation     *Federation
+	DtHealthHistory  *HealthHistory
+	DtKnowledgeBase  *KnowledgeBase
+	DtNodeTracker    *NodeTracker
+	PullRefresher    *PullRefresher
+	SystemdUnits     *SystemdUnits
+}
+
+type bundle = client.Bundle
 
 // UnitPropertiesResponse is a structure to unmarshal dbus.GetunitProperties response
 type UnitPropertiesResponse struct {
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary policies define when the cluster health summary responds with 503 Service Unavailable.
+const (
+	// SummaryPolicyAny fails the summary if any unit is unhealthy.
+	SummaryPolicyAny = "any"
+
+	// SummaryPolicyMasters fails the summary if any unit on a master node is unhealthy.
+	SummaryPolicyMasters = "masters"
+
+	// SummaryPolicyNone fails the summary only if the cluster health report is not available or too old.
+	SummaryPolicyNone = "none"
+)
+
+// Cluster statuses in the health summary.
+const (
+	summaryStatusHealthy = "healthy"
+
+	// summaryStatusDegraded means some units are unhealthy, but the summary policy passes.
+	summaryStatusDegraded  = "degraded"
+	summaryStatusUnhealthy = "unhealthy"
+
+	// summaryStatusUnknown means the cluster health report is not available or older than the max report age.
+	summaryStatusUnknown = "unknown"
+)
+
+// healthCounts counts healthy and unhealthy units or nodes.
+type healthCounts struct {
+	Total     int `json:"total"`
+	Healthy   int `json:"healthy"`
+	Unhealthy int `json:"unhealthy"`
+}
+
+func (c *healthCounts) add(health int) {
+	c.Total++
+	if health == 0 {
+		c.Healthy++
+		return
+	}
+	c.Unhealthy++
+}
+
+type failingUnit struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Health int      `json:"health"`
+	Title  string   `json:"description"`
+	Nodes  []string `json:"nodes"`
+}
+
+// cluster health summary response
+type summaryResponseJSONStruct struct {
+	Status           string                  `json:"status"`
+	Policy           string                  `json:"policy"`
+	UpdatedTime      *time.Time              `json:"updated,omitempty"`
+	ReportAgeSeconds float64                 `json:"report_age_seconds"`
+	Units            healthCounts            `json:"units"`
+	Nodes            map[string]healthCounts `json:"nodes"`
+	FailingUnits     []failingUnit           `json:"failing_units"`
+}
+
+// summaryPolicy returns the summary policy set in a config.
+func summaryPolicy(config *Config) string {
+	if config.FlagSummaryPolicy == "" {
+		return SummaryPolicyMasters
+	}
+	return config.FlagSummaryPolicy
+}
+
+// GetSummary returns the cluster health summary and reports whether it passes the summary policy. A report older than
+// maxAge fails any policy, e.g. if the puller is stuck the last report does not show the current cluster health.
+// Zero maxAge disables the check.
+func (mr *monitoringResponse) GetSummary(policy string, maxAge time.Duration,
+	now time.Time) (summaryResponseJSONStruct, bool) {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	summary := summaryResponseJSONStruct{
+		Status:       summaryStatusUnknown,
+		Policy:       policy,
+		Nodes:        make(map[string]healthCounts),
+		FailingUnits: []failingUnit{},
+	}
+	if mr.UpdatedTime.IsZero() || len(mr.Nodes) == 0 {
+		return summary, false
+	}
+	updated := mr.UpdatedTime
+	summary.UpdatedTime = &updated
+	summary.ReportAgeSeconds = now.Sub(mr.UpdatedTime).Seconds()
+
+	for _, node := range mr.Nodes {
+		counts := summary.Nodes[node.Role]
+		counts.add(node.Health)
+		summary.Nodes[node.Role] = counts
+	}
+
+	var failMasters bool
+	for name, u := range mr.Units {
+		summary.Units.add(u.Health)
+		// a unit could be healthy on some nodes only, e.g. if an aggregation policy requires a quorum.
+		if u.Health == 0 {
+			continue
+		}
+
+		failing := failingUnit{
+			ID:     name,
+			Name:   u.PrettyName,
+			Health: u.Health,
+			Title:  u.Title,
+			Nodes:  []string{},
+		}
+		for _, node := range u.Nodes {
+			unitOnNode := mr.unitOnNode(u, node)
+			if unitOnNode.Health == 0 {
+				continue
+			}
+			failing.Nodes = append(failing.Nodes, node.IP)
+			if node.Role == MasterRole {
+				failMasters = true
+			}
+		}
+		sort.Strings(failing.Nodes)
+		summary.FailingUnits = append(summary.FailingUnits, failing)
+	}
+	sort.Slice(summary.FailingUnits, func(i, j int) bool {
+		return summary.FailingUnits[i].ID < summary.FailingUnits[j].ID
+	})
+
+	ok := true
+	switch policy {
+	case SummaryPolicyAny:
+		ok = summary.Units.Unhealthy == 0
+	case SummaryPolicyMasters:
+		ok = !failMasters
+	}
+
+	switch {
+	case maxAge > 0 && now.Sub(mr.UpdatedTime) > maxAge:
+		summary.Status = summaryStatusUnknown
+		ok = false
+	case !ok:
+		summary.Status = summaryStatusUnhealthy
+	case summary.Units.Unhealthy > 0:
+		summary.Status = summaryStatusDegraded
+	default:
+		summary.Status = summaryStatusHealthy
+	}
+	return summary, ok
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// TLS modes define the schemes used to connect to 3dt on the cluster nodes.
+const (
+	// TLSModeOff uses http unless a scheme is set for a node by a discovery provider.
+	TLSModeOff = "off"
+
+	// TLSModePrefer tries https first and falls back to http. The scheme which worked is remembered for each node,
+	// so a cluster can be migrated to TLS node by node. A remembered http expires after nodeSchemeHTTPTTL.
+	TLSModePrefer = "prefer"
+
+	// TLSModeForce uses https only. The mode is used if -force-tls is set.
+	TLSModeForce = "force"
+)
+
+// nodeSchemeHTTPTTL is how long http negotiated with a node is tried first in prefer TLS mode. Once it expires https
+// is tried first again, so a node is switched to TLS after it is migrated.
+const nodeSchemeHTTPTTL = 10 * time.Minute
+
+// nodeSchemes keeps the schemes negotiated with the cluster nodes in prefer TLS mode.
+type nodeSchemes struct {
+	sync.RWMutex
+	schemes map[string]negotiatedScheme
+}
+
+// negotiatedScheme is a scheme which worked for a node and the time it was negotiated.
+type negotiatedScheme struct {
+	scheme     string
+	negotiated time.Time
+}
+
+func (s negotiatedScheme) expired() bool {
+	return s.scheme == "http" && time.Since(s.negotiated) > nodeSchemeHTTPTTL
+}
+
+var globalNodeSchemes = &nodeSchemes{
+	schemes: make(map[string]negotiatedScheme),
+}
+
+// get returns a scheme negotiated with a node unless it expired.
+func (n *nodeSchemes) get(ip string) (string, bool) {
+	n.RLock()
+	defer n.RUnlock()
+	s, ok := n.schemes[ip]
+	if !ok || s.expired() {
+		return "", false
+	}
+	return s.scheme, true
+}
+
+// set remembers a scheme which worked for a node. The negotiation time is kept while the scheme is used.
+func (n *nodeSchemes) set(ip, scheme string) {
+	n.Lock()
+	defer n.Unlock()
+	current, ok := n.schemes[ip]
+	if ok && current.scheme == scheme && !current.expired() {
+		return
+	}
+	if current.scheme != scheme {
+		logrus.Infof("Using %s to connect to node %s", scheme, ip)
+	}
+	n.schemes[ip] = negotiatedScheme{scheme: scheme, negotiated: time.Now()}
+}
+
+// tlsMode returns the TLS mode set in a config, -force-tls overrides -tls-mode.
+func tlsMode(config *Config) string {
+	if config.FlagForceTLS {
+		return TLSModeForce
+	}
+	if config.FlagTLSMode == "" {
+		return TLSModeOff
+	}
+	return config.FlagTLSMode
+}
+
+// nodeSchemesToTry returns the schemes allowed by the TLS mode to connect to a node, in order. In prefer mode
+// the scheme negotiated before is tried first until it expires.
+func nodeSchemesToTry(config *Config, node Node) []string {
+	switch tlsMode(config) {
+	case TLSModeForce:
+		return []string{"https"}
+	case TLSModePrefer:
+		first := "https"
+		if scheme, ok := globalNodeSchemes.get(node.IP); ok {
+			first = scheme
+		} else if node.Scheme != "" {
+			first = node.Scheme
+		}
+		if first == "http" {
+			return []string{"http", "https"}
+		}
+		return []string{"https", "http"}
+	}
+
+	if node.Scheme != "" {
+		return []string{node.Scheme}
+	}
+	return []string{"http"}
+}
+
+// doNodeRequest calls fn with a base URL of 3dt on a node for every scheme allowed by the TLS mode until fn
+// succeeds. fn should return an error if it could not connect to the node only, not if the node returned an error
+// status code. The next scheme is tried only if the node does not speak the scheme, a certificate which cannot be
+// verified fails the node. The scheme which worked is remembered for the node and returned.
+func doNodeRequest(config *Config, node Node, fn func(baseURL string) error) (string, error) {
+	var err error
+	for _, scheme := range nodeSchemesToTry(config, node) {
+		var baseURL string
+		baseURL, err = nodeURLWithScheme(config, node, scheme)
+		if err != nil {
+			return "", err
+		}
+
+		if err = fn(baseURL); err != nil {
+			if isCertificateError(err) {
+				logrus.Errorf("Could not verify the certificate of node %s: %s", node.IP, err)
+				return "", err
+			}
+			if !isSchemeMismatchError(err) {
+				return "", err
+			}
+			logrus.Debugf("Could not connect to node %s with %s: %s", node.IP, scheme, err)
+			continue
+		}
+
+		if tlsMode(config) == TLSModePrefer {
+			globalNodeSchemes.set(node.IP, scheme)
+		}
+		return scheme, nil
+	}
+	return "", err
+}
+
+// nodeURLWithScheme returns a URL to connect to 3dt on a node with a given scheme, e.g. https://10.0.0.1:1050.
+func nodeURLWithScheme(config *Config, node Node, scheme string) (string, error) {
+	port, err := getNodePort(config, node)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, node.IP, port), nil
+}
+
+// isSchemeMismatchError returns true if an error means a node does not speak a scheme: the connection is refused or
+// https is used to talk to a plain http server or vice versa.
+func isSchemeMismatchError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &recordHeaderErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "server gave HTTP response to HTTPS client") ||
+		strings.Contains(msg, "malformed HTTP response") ||
+		strings.Contains(msg, "connection refused")
+}
+
+// isCertificateError returns true if a node certificate could not be verified.
+func isCertificateError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		unknownAuthErr  x509.UnknownAuthorityError
+		invalidErr      x509.CertificateInvalidError
+		hostnameErr     x509.HostnameError
+	)
+	return errors.As(err, &verificationErr) || errors.As(err, &unknownAuthErr) || errors.As(err, &invalidErr) ||
+		errors.As(err, &hostnameErr)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"sort"
+	"time"
+)
+
+// The v2 API addresses nodes by Mesos ID, hostname or IP address and returns complete resources: units embed the
+// health of the unit on every node, nodes embed the units running on the node.
+
+// nodeRefV2 identifies a node. The node ID is the Mesos ID if known, the IP address otherwise.
+type nodeRefV2 struct {
+	NodeID   string `json:"node_id"`
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname"`
+	MesosID  string `json:"mesos_id"`
+	Role     string `json:"role"`
+	Leader   bool   `json:"leader"`
+}
+
+// unitOnNodeV2 is the health of a unit on a node.
+type unitOnNodeV2 struct {
+	nodeRefV2
+	UnitID    string    `json:"unit_id"`
+	Name      string    `json:"name"`
+	Health    int       `json:"health"`
+	Title     string    `json:"description"`
+	Output    string    `json:"output"`
+	Help      string    `json:"help"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// unitV2 is a unit aggregated from all nodes.
+type unitV2 struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Health      int              `json:"health"`
+	Title       string           `json:"description"`
+	Timestamp   time.Time        `json:"timestamp"`
+	Aggregation *unitAggregation `json:"aggregation,omitempty"`
+	Nodes       []unitOnNodeV2   `json:"nodes"`
+}
+
+// nodeV2 is a node with the units running on it.
+type nodeV2 struct {
+	nodeRefV2
+	Health      int            `json:"health"`
+	DCOSVersion string         `json:"dcos_version,omitempty"`
+	TDTVersion  string         `json:"3dt_version,omitempty"`
+	Units       []unitOnNodeV2 `json:"units"`
+}
+
+type unitsResponseV2 struct {
+	Array []unitV2 `json:"units"`
+}
+
+type nodesResponseV2 struct {
+	Array []nodeV2 `json:"nodes"`
+}
+
+func newNodeRefV2(node Node) nodeRefV2 {
+	id := node.MesosID
+	if id == "" {
+		id = node.IP
+	}
+	return nodeRefV2{
+		NodeID:   id,
+		IP:       node.IP,
+		Hostname: node.Host,
+		MesosID:  node.MesosID,
+		Role:     node.Role,
+		Leader:   node.Leader,
+	}
+}
+
+func newUnitOnNodeV2(u unit, node Node) unitOnNodeV2 {
+	return unitOnNodeV2{
+		nodeRefV2: newNodeRefV2(node),
+		UnitID:    u.UnitName,
+		Name:      u.PrettyName,
+		Health:    u.Health,
+		Title:     u.Title,
+		Output:    node.Output[u.UnitName],
+		Help:      unitHelp(node, u.UnitName),
+		Timestamp: u.Timestamp,
+	}
+}
+
+// unitOnNode returns the health of a unit reported by a node. Synthetic units, e.g. the missing nodes unit,
+// reference nodes which did not report the unit, the node health is used for them.
+func (mr *monitoringResponse) unitOnNode(u unit, node Node) unitOnNodeV2 {
+	if n, ok := mr.Nodes[node.IP]; ok {
+		for _, nodeUnit := range n.Units {
+			if nodeUnit.UnitName == u.UnitName {
+				return newUnitOnNodeV2(nodeUnit, n)
+			}
+		}
+	}
+	nodeUnit := u
+	nodeUnit.Health = node.Health
+	return newUnitOnNodeV2(nodeUnit, node)
+}
+
+func (mr *monitoringResponse) unitV2(u unit) unitV2 {
+	response := unitV2{
+		ID:          u.UnitName,
+		Name:        u.PrettyName,
+		Health:      u.Health,
+		Title:       u.Title,
+		Timestamp:   u.Timestamp,
+		Aggregation: u.Aggregation,
+		Nodes:       []unitOnNodeV2{},
+	}
+	for _, node := range u.Nodes {
+		response.Nodes = append(response.Nodes, mr.unitOnNode(u, node))
+	}
+	sort.Slice(response.Nodes, func(i, j int) bool {
+		return response.Nodes[i].IP < response.Nodes[j].IP
+	})
+	return response
+}
+
+func nodeToV2(node Node) nodeV2 {
+	response := nodeV2{
+		nodeRefV2:   newNodeRefV2(node),
+		Health:      node.Health,
+		DCOSVersion: node.DCOSVersion,
+		TDTVersion:  node.TDTVersion,
+		Units:       []unitOnNodeV2{},
+	}
+	for _, u := range node.Units {
+		response.Units = append(response.Units, newUnitOnNodeV2(u, node))
+	}
+	sort.Slice(response.Units, func(i, j int) bool {
+		return response.Units[i].UnitID < response.Units[j].UnitID
+	})
+	return response
+}
+
+// findNode returns a node by IP address, Mesos ID or hostname. The caller must hold the lock.
+func (mr *monitoringResponse) findNode(nodeID string) (Node, error) {
+	if node, ok := mr.Nodes[nodeID]; ok {
+		return node, nil
+	}
+
+	var found []Node
+	for _, node := range mr.Nodes {
+		if node.MesosID == nodeID || node.Host == nodeID {
+			found = append(found, node)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Node{}, notFoundError("Node %s not found", nodeID)
+	case 1:
+		return found[0], nil
+	}
+	return Node{}, invalidArgumentError("Node ID %s is ambiguous, %d nodes found. Use a Mesos ID or an IP address",
+		nodeID, len(found))
+}
+
+// resolveNodeIP returns an IP address of a node by IP address, Mesos ID or hostname.
+func (mr *monitoringResponse) resolveNodeIP(nodeID string) (string, bool) {
+	mr.RLock()
+	defer mr.RUnlock()
+	node, err := mr.findNode(nodeID)
+	if err != nil {
+		return "", false
+	}
+	return node.IP, true
+}
+
+// GetUnitsV2 returns all units with the unit health on every node, sorted by unit ID.
+func (mr *monitoringResponse) GetUnitsV2() unitsResponseV2 {
+	mr.RLock()
+	defer mr.RUnlock()
+	return unitsResponseV2{Array: mr.unitsV2()}
+}
+
+// unitsV2 returns all units sorted by ID. The caller must hold the lock.
+func (mr *monitoringResponse) unitsV2() []unitV2 {
+	units := []unitV2{}
+	for _, u := range mr.Units {
+		units = append(units, mr.unitV2(u))
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units
+}
+
+// GetUnitV2 returns a unit with the unit health on every node.
+func (mr *monitoringResponse) GetUnitV2(unitID string) (unitV2, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+	u, ok := mr.Units[unitID]
+	if !ok {
+		return unitV2{}, notFoundError("Unit %s not found", unitID)
+	}
+	return mr.unitV2(u), nil
+}
+
+// GetNodesV2 returns all nodes with their units, sorted by IP address.
+func (mr *monitoringResponse) GetNodesV2() nodesResponseV2 {
+	mr.RLock()
+	defer mr.RUnlock()
+	return nodesResponseV2{Array: mr.nodesV2()}
+}
+
+// nodesV2 returns all nodes sorted by IP address. The caller must hold the lock.
+func (mr *monitoringResponse) nodesV2() []nodeV2 {
+	nodes := []nodeV2{}
+	for _, node := range mr.Nodes {
+		nodes = append(nodes, nodeToV2(node))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].IP < nodes[j].IP
+	})
+	return nodes
+}
+
+// GetNodeV2 returns a node with its units.
+func (mr *monitoringResponse) GetNodeV2(nodeID string) (nodeV2, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+	node, err := mr.findNode(nodeID)
+	if err != nil {
+		return nodeV2{}, err
+	}
+	return nodeToV2(node), nil
+}
+
+// GetUnitOnNodeV2 returns the health of a unit on a node.
+func (mr *monitoringResponse) GetUnitOnNodeV2(nodeID, unitID string) (unitOnNodeV2, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+	node, err := mr.findNode(nodeID)
+	if err != nil {
+		return unitOnNodeV2{}, err
+	}
+
+	u, ok := mr.Units[unitID]
+	if !ok {
+		return unitOnNodeV2{}, notFoundError("Unit %s not found", unitID)
+	}
+	for _, n := range u.Nodes {
+		if n.IP == node.IP {
+			return mr.unitOnNode(u, n), nil
+		}
+	}
+	return unitOnNodeV2{}, notFoundError("Unit %s not found on node %s", unitID, nodeID)
+}
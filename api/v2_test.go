@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type V2TestSuit struct {
+	suite.Suite
+	assert *assertPackage.Assertions
+	router *mux.Router
+}
+
+func (s *V2TestSuit) SetupTest() {
+	s.assert = assertPackage.New(s.T())
+	dt := Dt{Cfg: &testCfg, DtDCOSTools: &fakeDCOSTools{}}
+	s.router = NewRouter(dt)
+
+	responses := make(chan *httpResponse, 2)
+	responses <- newHostResponse(Node{IP: "10.0.7.1", Role: MasterRole, Leader: true}, http.StatusOK,
+		UnitsHealthResponseJSONStruct{
+			Hostname: "master-1",
+			MesosID:  "master-id",
+			Array: []healthResponseValues{
+				{UnitID: "dcos-mesos-master.service", PrettyName: "Mesos Master", UnitTitle: "Mesos master"},
+				{UnitID: "dcos-marathon.service", PrettyName: "Marathon", UnitTitle: "Marathon", UnitHealth: 1,
+					UnitOutput: "marathon failed"},
+			},
+		}, dt)
+	responses <- newHostResponse(Node{IP: "10.0.7.2", Role: AgentRole}, http.StatusOK,
+		UnitsHealthResponseJSONStruct{
+			Hostname: "agent-1",
+			MesosID:  "agent-id",
+			Array: []healthResponseValues{
+				{UnitID: "dcos-mesos-slave.service", PrettyName: "Mesos Agent", UnitTitle: "Mesos agent"},
+			},
+		}, dt)
+	updateHealthStatus(responses, nil)
+}
+
+func (s *V2TestSuit) TearDownTest() {
+	globalMonitoringResponse = monitoringResponse{}
+}
+
+func (s *V2TestSuit) get(url string, code int, v interface{}) {
+	response, statusCode, err := MakeHTTPRequest(s.T(), s.router, url, "GET", nil)
+	s.assert.NoError(err)
+	s.assert.Equal(code, statusCode, url)
+	s.assert.NoError(json.Unmarshal(response, v))
+}
+
+func (s *V2TestSuit) TestUnits() {
+	var units unitsResponseV2
+	s.get(BaseRouteV2+"/units", http.StatusOK, &units)
+	s.assert.Len(units.Array, 3)
+
+	marathon := units.Array[0]
+	s.assert.Equal("dcos-marathon.service", marathon.ID)
+	s.assert.Equal(1, marathon.Health)
+	s.assert.False(marathon.Timestamp.IsZero())
+	s.assert.Len(marathon.Nodes, 1)
+
+	node := marathon.Nodes[0]
+	s.assert.Equal(nodeRefV2{
+		NodeID:   "master-id",
+		IP:       "10.0.7.1",
+		Hostname: "master-1",
+		MesosID:  "master-id",
+		Role:     MasterRole,
+		Leader:   true,
+	}, node.nodeRefV2)
+	s.assert.Equal(1, node.Health)
+	s.assert.Equal("marathon failed", node.Output)
+	s.assert.Contains(node.Help, "dcos node ssh -mesos-id master-id")
+	s.assert.False(node.Timestamp.IsZero())
+}
+
+func (s *V2TestSuit) TestNodeIDs() {
+	// a node is addressed by IP address, Mesos ID or hostname.
+	for _, id := range []string{"10.0.7.2", "agent-id", "agent-1"} {
+		var node nodeV2
+		s.get(BaseRouteV2+"/nodes/"+id, http.StatusOK, &node)
+		s.assert.Equal("10.0.7.2", node.IP, id)
+		s.assert.Equal(AgentRole, node.Role)
+		s.assert.False(node.Leader)
+		s.assert.Len(node.Units, 1)
+		s.assert.Equal("dcos-mesos-slave.service", node.Units[0].UnitID)
+	}
+
+	var u unitOnNodeV2
+	s.get(BaseRouteV2+"/nodes/master-1/units/dcos-marathon.service", http.StatusOK, &u)
+	s.assert.Equal("marathon failed", u.Output)
+	s.assert.Equal(1, u.Health)
+
+	u = unitOnNodeV2{}
+	s.get(BaseRouteV2+"/units/dcos-mesos-master.service/nodes/master-id", http.StatusOK, &u)
+	s.assert.Equal("10.0.7.1", u.IP)
+	s.assert.Equal(0, u.Health)
+
+	var e errorResponse
+	s.get(BaseRouteV2+"/nodes/unknown", http.StatusNotFound, &e)
+	s.assert.Equal("Node unknown not found", e.Message)
+	s.get(BaseRouteV2+"/units/dcos-marathon.service/nodes/agent-1", http.StatusNotFound, &e)
+	s.get(BaseRouteV2+"/units/dcos-unknown.service", http.StatusNotFound, &e)
+}
+
+func (s *V2TestSuit) TestNodes() {
+	var nodes nodesResponseV2
+	s.get(BaseRouteV2+"/nodes", http.StatusOK, &nodes)
+	s.assert.Len(nodes.Array, 2)
+	s.assert.Equal("10.0.7.1", nodes.Array[0].IP)
+	s.assert.Equal(1, nodes.Array[0].Health)
+	s.assert.Len(nodes.Array[0].Units, 2)
+	s.assert.Equal("dcos-marathon.service", nodes.Array[0].Units[0].UnitID)
+	s.assert.Equal("10.0.7.2", nodes.Array[1].IP)
+
+	// v1 responses do not change.
+	var v1 nodesResponseJSONStruct
+	s.get(BaseRoute+"/nodes", http.StatusOK, &v1)
+	s.assert.Equal([]*nodeResponseFieldsStruct{
+		{HostIP: "10.0.7.1", NodeHealth: 1, NodeRole: MasterRole},
+		{HostIP: "10.0.7.2", NodeHealth: 0, NodeRole: AgentRole},
+	}, v1.Array)
+}
+
+func TestV2TestSuit(t *testing.T) {
+	suite.Run(t, new(V2TestSuit))
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionSkewUnitName is a synthetic cluster unit, unhealthy if the cluster nodes run different DC/OS or 3DT versions.
+const versionSkewUnitName = "dcos-version-skew"
+
+// versionsResponseJSONStruct groups the cluster nodes by DC/OS and 3DT versions.
+type versionsResponseJSONStruct struct {
+	DCOSVersions []versionGroup `json:"dcos_versions"`
+	TDTVersions  []versionGroup `json:"3dt_versions"`
+	Skew         bool           `json:"skew"`
+}
+
+type versionGroup struct {
+	Version string                      `json:"version"`
+	Nodes   []*nodeResponseFieldsStruct `json:"nodes"`
+}
+
+// groupNodesByVersion returns the nodes grouped by a version, sorted by version. Nodes which did not report
+// a version are skipped.
+func groupNodesByVersion(nodes map[string]Node, version func(Node) string) []versionGroup {
+	groups := make(map[string][]*nodeResponseFieldsStruct)
+	for _, node := range nodes {
+		if v := version(node); v != "" {
+			groups[v] = append(groups[v], &nodeResponseFieldsStruct{
+				HostIP:     node.IP,
+				NodeHealth: node.Health,
+				NodeRole:   node.Role,
+			})
+		}
+	}
+
+	var result []versionGroup
+	for v, groupNodes := range groups {
+		sort.Slice(groupNodes, func(i, j int) bool {
+			return groupNodes[i].HostIP < groupNodes[j].HostIP
+		})
+		result = append(result, versionGroup{Version: v, Nodes: groupNodes})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersions(result[i].Version, result[j].Version) < 0
+	})
+	return result
+}
+
+// referenceVersion returns the version the cluster is expected to run. DC/OS upgrades masters first, so the most
+// common version on masters is used. If no master reported a version, the most common version in the cluster is used.
+func referenceVersion(nodes map[string]Node, version func(Node) string) string {
+	count := func(masters bool) string {
+		counter := make(map[string]int)
+		for _, node := range nodes {
+			if v := version(node); v != "" && (!masters || node.Role == MasterRole) {
+				counter[v]++
+			}
+		}
+
+		var reference string
+		for v, n := range counter {
+			// prefer a higher version if the numbers of nodes are equal
+			if n > counter[reference] || (n == counter[reference] && compareVersions(v, reference) > 0) {
+				reference = v
+			}
+		}
+		return reference
+	}
+
+	if reference := count(true); reference != "" {
+		return reference
+	}
+	return count(false)
+}
+
+// compareVersions compares dot separated versions segment by segment, e.g. 1.9 < 1.10 < 1.10.1. The leading digits of
+// segments are compared as numbers, the rest of segments as strings. The result is -1, 0 or 1.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, restX := splitVersionSegment(as[i])
+		y, restY := splitVersionSegment(bs[i])
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		case restX < restY:
+			return -1
+		case restX > restY:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// splitVersionSegment returns the number a version segment starts with, or -1, and the rest of the segment.
+func splitVersionSegment(segment string) (int, string) {
+	i := 0
+	for i < len(segment) && segment[i] >= '0' && segment[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(segment[:i])
+	if err != nil {
+		return -1, segment
+	}
+	return n, segment[i:]
+}
+
+func nodeDCOSVersion(node Node) string {
+	return node.DCOSVersion
+}
+
+func nodeTDTVersion(node Node) string {
+	return node.TDTVersion
+}
+
+// versionSkewUnit returns a synthetic unit with the nodes running a DC/OS or 3DT version other than the reference
+// version. The second return value is false if no node reported a version.
+func versionSkewUnit(nodes map[string]Node) (unit, bool) {
+	dcosVersion := referenceVersion(nodes, nodeDCOSVersion)
+	tdtVersion := referenceVersion(nodes, nodeTDTVersion)
+	if dcosVersion == "" && tdtVersion == "" {
+		return unit{}, false
+	}
+
+	u := unit{
+		UnitName:   versionSkewUnitName,
+		Title:      fmt.Sprintf("All nodes run DC/OS version %s and 3DT version %s", dcosVersion, tdtVersion),
+		PrettyName: "Version Skew",
+		Timestamp:  time.Now(),
+	}
+
+	var skewed []string
+	for _, node := range nodes {
+		if (node.DCOSVersion != "" && node.DCOSVersion != dcosVersion) ||
+			(node.TDTVersion != "" && node.TDTVersion != tdtVersion) {
+			// the unit references the node, the node units and system metrics are kept on the node only.
+			u.Nodes = append(u.Nodes, Node{
+				IP:          node.IP,
+				Role:        node.Role,
+				Host:        node.Host,
+				MesosID:     node.MesosID,
+				DCOSVersion: node.DCOSVersion,
+				TDTVersion:  node.TDTVersion,
+				Health:      node.Health,
+			})
+			skewed = append(skewed, fmt.Sprintf("%s (DC/OS %s, 3DT %s)", node.IP, node.DCOSVersion, node.TDTVersion))
+		}
+	}
+
+	if len(skewed) > 0 {
+		sort.Strings(skewed)
+		u.Health = 1
+		u.Title = fmt.Sprintf("Expected DC/OS version %s and 3DT version %s, nodes with different versions: %s",
+			dcosVersion, tdtVersion, strings.Join(skewed, ", "))
+	}
+	return u, true
+}
+
+// GetVersions returns the cluster nodes grouped by DC/OS and 3DT versions.
+func (mr *monitoringResponse) GetVersions() versionsResponseJSONStruct {
+	mr.RLock()
+	defer mr.RUnlock()
+	response := versionsResponseJSONStruct{
+		DCOSVersions: groupNodesByVersion(mr.Nodes, nodeDCOSVersion),
+		TDTVersions:  groupNodesByVersion(mr.Nodes, nodeTDTVersion),
+	}
+	response.Skew = len(response.DCOSVersions) > 1 || len(response.TDTVersions) > 1
+	return response
+}
@@ -0,0 +1,225 @@
+// Package client is a Go client of the 3dt API.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BaseRoute is a base route of the 3dt API.
+const BaseRoute = "/system/health/v1"
+
+// Client makes requests to a 3dt API. A base URL is a node address, e.g. http://10.0.0.1:1050, or a cluster URL
+// behind adminrouter, e.g. https://master.mesos. The base URL must not include the base route.
+type Client struct {
+	baseURL   string
+	requester Requester
+	timeout   time.Duration
+}
+
+// New returns a client of a 3dt API. Every request is made with the requester and times out after the timeout.
+func New(baseURL string, requester Requester, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		requester: requester,
+		timeout:   timeout,
+	}
+}
+
+// Error is an error response of a 3dt API.
+type Error struct {
+	StatusCode int
+	ErrorResponse
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("3dt responded with status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("3dt responded with status code %d: %s", e.StatusCode, e.Message)
+}
+
+// newError returns an error of an error response. Diagnostics responses set the message in the status field.
+func newError(statusCode int, body []byte) *Error {
+	e := &Error{StatusCode: statusCode}
+	var response struct {
+		ErrorResponse
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		e.Message = strings.TrimSpace(string(body))
+		return e
+	}
+	e.ErrorResponse = response.ErrorResponse
+	if e.Message == "" {
+		e.Message = response.Status
+	}
+	return e
+}
+
+// readError reads an error response.
+func readError(resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return newError(resp.StatusCode, body)
+}
+
+// Do makes a request to a path of the API. The caller is responsible to close the response body. A response with
+// an error status code is returned as *Error.
+func (c *Client) Do(method, path string, body io.Reader) (*http.Response, error) {
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, readError(resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) request(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.requester.Do(req, c.timeout)
+}
+
+// Get makes a GET request to a path of the API and decodes a JSON response into v.
+func (c *Client) Get(path string, v interface{}) error {
+	return c.decode("GET", path, nil, v)
+}
+
+func (c *Client) decode(method, path string, body io.Reader, v interface{}) error {
+	resp, err := c.Do(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// withQuery adds a query to a path, e.g. a filter of a units or nodes response.
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// Health returns the health of the units on the node serving the API.
+func (c *Client) Health() (HealthResponse, error) {
+	var response HealthResponse
+	err := c.Get(BaseRoute, &response)
+	return response, err
+}
+
+// Report returns the cluster health report.
+func (c *Client) Report() (Report, error) {
+	var response Report
+	err := c.Get(BaseRoute+"/report", &response)
+	return response, err
+}
+
+// Units returns the cluster health of the units. The query filters, sorts and paginates the units.
+func (c *Client) Units(query url.Values) (UnitsResponse, error) {
+	var response UnitsResponse
+	err := c.Get(withQuery(BaseRoute+"/units", query), &response)
+	return response, err
+}
+
+// Unit returns the cluster health of a unit.
+func (c *Client) Unit(unitID string) (UnitResponse, error) {
+	var response UnitResponse
+	err := c.Get(BaseRoute+"/units/"+url.PathEscape(unitID), &response)
+	return response, err
+}
+
+// UnitNodes returns the nodes running a unit. The query filters, sorts and paginates the nodes.
+func (c *Client) UnitNodes(unitID string, query url.Values) (NodesResponse, error) {
+	var response NodesResponse
+	err := c.Get(withQuery(BaseRoute+"/units/"+url.PathEscape(unitID)+"/nodes", query), &response)
+	return response, err
+}
+
+// UnitNode returns the health of a unit on a node.
+func (c *Client) UnitNode(unitID, nodeID string) (NodeUnitResponse, error) {
+	var response NodeUnitResponse
+	err := c.Get(BaseRoute+"/units/"+url.PathEscape(unitID)+"/nodes/"+url.PathEscape(nodeID), &response)
+	return response, err
+}
+
+// Nodes returns the health of the cluster nodes. The query filters, sorts and paginates the nodes.
+func (c *Client) Nodes(query url.Values) (NodesResponse, error) {
+	var response NodesResponse
+	err := c.Get(withQuery(BaseRoute+"/nodes", query), &response)
+	return response, err
+}
+
+// Node returns the health of a node.
+func (c *Client) Node(nodeID string) (NodeResponse, error) {
+	var response NodeResponse
+	err := c.Get(BaseRoute+"/nodes/"+url.PathEscape(nodeID), &response)
+	return response, err
+}
+
+// NodeUnits returns the units of a node. The query filters, sorts and paginates the units.
+func (c *Client) NodeUnits(nodeID string, query url.Values) (UnitsResponse, error) {
+	var response UnitsResponse
+	err := c.Get(withQuery(BaseRoute+"/nodes/"+url.PathEscape(nodeID)+"/units", query), &response)
+	return response, err
+}
+
+// NodeUnit returns the health of a unit on a node with the unit output.
+func (c *Client) NodeUnit(nodeID, unitID string) (UnitHealth, error) {
+	var response UnitHealth
+	err := c.Get(BaseRoute+"/nodes/"+url.PathEscape(nodeID)+"/units/"+url.PathEscape(unitID), &response)
+	return response, err
+}
+
+// Logs returns the logs available on the node serving the API, a map of a log name and its URL.
+func (c *Client) Logs() (map[string]string, error) {
+	response := make(map[string]string)
+	err := c.Get(BaseRoute+"/logs", &response)
+	return response, err
+}
+
+// Log returns a log of an entity, e.g. a systemd unit of the units provider. The caller is responsible to close the
+// log.
+func (c *Client) Log(provider, entity string) (io.ReadCloser, error) {
+	resp, err := c.Do("GET", BaseRoute+"/logs/"+url.PathEscape(provider)+"/"+url.PathEscape(entity), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// NodeLogs returns the logs available on a node, a map of a log name and its URL. The logs served by 3dt on the node
+// are listed with the master routes proxying the logs.
+func (c *Client) NodeLogs(nodeID string) (map[string]string, error) {
+	response := make(map[string]string)
+	err := c.Get(BaseRoute+"/nodes/"+url.PathEscape(nodeID)+"/logs", &response)
+	return response, err
+}
+
+// NodeLog returns a log of an entity on a node, proxied by a master. The caller is responsible to close the log.
+func (c *Client) NodeLog(nodeID, provider, entity string) (io.ReadCloser, error) {
+	resp, err := c.Do("GET", BaseRoute+"/nodes/"+url.PathEscape(nodeID)+"/logs/"+url.PathEscape(provider)+"/"+
+		url.PathEscape(entity), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuit struct {
+	suite.Suite
+	assert   *assertPackage.Assertions
+	server   *httptest.Server
+	client   *Client
+	requests []*http.Request
+}
+
+func (s *ClientTestSuit) SetupTest() {
+	s.assert = assertPackage.New(s.T())
+	s.requests = nil
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(BaseRoute+"/units", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(UnitsResponse{
+			Array: []UnitResponse{{UnitID: "dcos-marathon.service", PrettyName: "Marathon", UnitHealth: 1}},
+			Total: 1,
+		})
+	})
+	mux.HandleFunc(BaseRoute+"/nodes/10.0.0.2", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Code: "not_found", Message: "Node 10.0.0.2 not found",
+			RequestID: "abc"})
+	})
+	mux.HandleFunc(BaseRoute+"/report/diagnostics/cancel", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(DiagnosticsResponse{ResponseCode: http.StatusConflict, Version: 1,
+			Status: "Job is not running", Code: "conflict", Message: "Job is not running", RequestID: "abc"})
+	})
+	mux.HandleFunc(BaseRoute+"/report/diagnostics/create", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateRequest
+		s.assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		s.assert.Equal([]string{"all"}, req.Nodes)
+		response := CreateResponse{DiagnosticsResponse: DiagnosticsResponse{ResponseCode: http.StatusOK,
+			Status: "Job has been successfully started"}}
+		response.Extra.LastBundleFile = "bundle-2017-01-01T00:00:00-000000000.zip"
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc(BaseRoute+"/logs/units/dcos-marathon.service", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("marathon log"))
+	})
+	mux.HandleFunc(BaseRoute+"/nodes/agent-1/logs/units/dcos-marathon.service", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("agent marathon log"))
+	})
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r)
+		mux.ServeHTTP(w, r)
+	}))
+	requester, err := NewRequester(Options{Headers: map[string]string{"Authorization": "token=secret"}})
+	s.assert.NoError(err)
+	s.client = New(s.server.URL+"/", requester, time.Second)
+}
+
+func (s *ClientTestSuit) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientTestSuit) TestUnits() {
+	units, err := s.client.Units(url.Values{"health": []string{"1"}})
+	s.assert.NoError(err)
+	s.assert.Equal(1, units.Total)
+	s.assert.Equal("dcos-marathon.service", units.Array[0].UnitID)
+
+	s.assert.Len(s.requests, 1)
+	s.assert.Equal("health=1", s.requests[0].URL.RawQuery)
+	s.assert.Equal("token=secret", s.requests[0].Header.Get("Authorization"))
+}
+
+func (s *ClientTestSuit) TestError() {
+	_, err := s.client.Node("10.0.0.2")
+	e, ok := err.(*Error)
+	s.assert.True(ok)
+	s.assert.Equal(http.StatusNotFound, e.StatusCode)
+	s.assert.Equal("not_found", e.Code)
+	s.assert.Equal("abc", e.RequestID)
+	s.assert.Equal("3dt responded with status code 404: Node 10.0.0.2 not found", e.Error())
+
+	// a response which is not JSON is used as a message.
+	_, err = s.client.Unit("dcos-unknown.service")
+	e, ok = err.(*Error)
+	s.assert.True(ok)
+	s.assert.Equal(http.StatusNotFound, e.StatusCode)
+	s.assert.Equal("404 page not found", e.Message)
+}
+
+func (s *ClientTestSuit) TestDiagnostics() {
+	created, err := s.client.CreateBundle([]string{"all"})
+	s.assert.NoError(err)
+	s.assert.Equal(http.StatusOK, created.ResponseCode)
+	s.assert.Equal("bundle-2017-01-01T00:00:00-000000000.zip", created.Extra.LastBundleFile)
+	s.assert.Equal("application/json", s.requests[0].Header.Get("Content-Type"))
+
+	// the diagnostics response is returned with an error.
+	response, err := s.client.CancelBundle()
+	s.assert.IsType(&Error{}, err)
+	s.assert.Equal(http.StatusConflict, response.ResponseCode)
+	s.assert.Equal("Job is not running", response.Status)
+	s.assert.Equal("conflict", response.Code)
+	s.assert.Equal(1, response.Version)
+	s.assert.Equal("POST", s.requests[1].Method)
+}
+
+func (s *ClientTestSuit) TestLog() {
+	log, err := s.client.Log("units", "dcos-marathon.service")
+	s.assert.NoError(err)
+	defer log.Close()
+	b, err := ioutil.ReadAll(log)
+	s.assert.NoError(err)
+	s.assert.Equal("marathon log", string(b))
+
+	nodeLog, err := s.client.NodeLog("agent-1", "units", "dcos-marathon.service")
+	s.assert.NoError(err)
+	defer nodeLog.Close()
+	b, err = ioutil.ReadAll(nodeLog)
+	s.assert.NoError(err)
+	s.assert.Equal("agent marathon log", string(b))
+}
+
+func (s *ClientTestSuit) TestNewRequesterCAFile() {
+	_, err := NewRequester(Options{CACertFile: "/tmp/3dt-missing-ca.crt"})
+	s.assert.Error(err)
+
+	f, err := ioutil.TempFile("", "3dt-ca")
+	s.assert.NoError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("not a certificate")
+	_, err = NewRequester(Options{CACertFile: f.Name()})
+	s.assert.EqualError(err, "CACertFile parsing failed")
+}
+
+func TestClientTestSuit(t *testing.T) {
+	suite.Run(t, new(ClientTestSuit))
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// diagnosticsRoute is a base route of the diagnostics bundle endpoints.
+const diagnosticsRoute = BaseRoute + "/report/diagnostics"
+
+// doDiagnostics makes a request to a diagnostics job endpoint and decodes a response into v. The diagnostics
+// endpoints respond with a diagnostics response on error, it is decoded into v and *Error is returned.
+func (c *Client) doDiagnostics(method, path string, body io.Reader, v interface{}) error {
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusBadRequest {
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	e := newError(resp.StatusCode, b)
+	switch response := v.(type) {
+	case *DiagnosticsResponse:
+		response.fromError(e, b)
+	case *CreateResponse:
+		response.fromError(e, b)
+	}
+	return e
+}
+
+// fromError sets a diagnostics response of an error response. A response which is not a diagnostics response is
+// replaced with the error.
+func (d *DiagnosticsResponse) fromError(e *Error, body []byte) {
+	if err := json.Unmarshal(body, d); err == nil && d.ResponseCode != 0 {
+		return
+	}
+	*d = DiagnosticsResponse{
+		ResponseCode: e.StatusCode,
+		Status:       e.Message,
+		Code:         e.Code,
+		Message:      e.Message,
+		RequestID:    e.RequestID,
+	}
+}
+
+// CreateBundle starts a diagnostics job collecting a bundle from the nodes, e.g. "all", "masters", "agents" or
+// IP addresses.
+func (c *Client) CreateBundle(nodes []string) (CreateResponse, error) {
+	var response CreateResponse
+	body, err := json.Marshal(CreateRequest{Nodes: nodes})
+	if err != nil {
+		return response, err
+	}
+	err = c.doDiagnostics("POST", diagnosticsRoute+"/create", bytes.NewReader(body), &response)
+	return response, err
+}
+
+// BundleStatus returns the diagnostics job status of the node serving the API.
+func (c *Client) BundleStatus() (BundleStatus, error) {
+	var response BundleStatus
+	err := c.doDiagnostics("GET", diagnosticsRoute+"/status", nil, &response)
+	return response, err
+}
+
+// BundleStatusAll returns the diagnostics job status of every master, a map of a master IP address and its status.
+func (c *Client) BundleStatusAll() (map[string]BundleStatus, error) {
+	response := make(map[string]BundleStatus)
+	err := c.doDiagnostics("GET", diagnosticsRoute+"/status/all", nil, &response)
+	return response, err
+}
+
+// CancelBundle cancels a running diagnostics job.
+func (c *Client) CancelBundle() (DiagnosticsResponse, error) {
+	var response DiagnosticsResponse
+	err := c.doDiagnostics("POST", diagnosticsRoute+"/cancel", nil, &response)
+	return response, err
+}
+
+// ListBundles returns the bundles available on the node serving the API.
+func (c *Client) ListBundles() ([]Bundle, error) {
+	var response []Bundle
+	err := c.doDiagnostics("GET", diagnosticsRoute+"/list", nil, &response)
+	return response, err
+}
+
+// ListAllBundles returns the bundles available in the cluster, a map of a master address and its bundles.
+func (c *Client) ListAllBundles() (map[string][]Bundle, error) {
+	response := make(map[string][]Bundle)
+	err := c.doDiagnostics("GET", diagnosticsRoute+"/list/all", nil, &response)
+	return response, err
+}
+
+// DeleteBundle deletes a bundle from the cluster.
+func (c *Client) DeleteBundle(name string) (DiagnosticsResponse, error) {
+	var response DiagnosticsResponse
+	err := c.doDiagnostics("POST", diagnosticsRoute+"/delete/"+url.PathEscape(name), nil, &response)
+	return response, err
+}
+
+// DownloadBundle returns a bundle zip archive. The caller is responsible to close the archive.
+func (c *Client) DownloadBundle(name string) (io.ReadCloser, error) {
+	resp, err := c.Do("GET", diagnosticsRoute+"/serve/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Requester makes HTTP requests with a timeout.
+type Requester interface {
+	Do(*http.Request, time.Duration) (*http.Response, error)
+}
+
+// Options are the options of a requester made by NewRequester.
+type Options struct {
+	// CACertFile is a certificate authority used to verify HTTPS servers. HTTPS certificates are not verified if the
+	// file is not set.
+	CACertFile string
+
+	// Headers are added to every request, e.g. an authorization token.
+	Headers map[string]string
+}
+
+// NewRequester returns a requester which makes HTTP and HTTPS requests. HTTPS requests are verified with the
+// certificate authority set in the options.
+func NewRequester(options Options) (Requester, error) {
+	caPool, err := LoadCAPool(options.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	return &requester{
+		secureTransport: NewSecureTransport(caPool),
+		transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+		headers: options.Headers,
+	}, nil
+}
+
+type requester struct {
+	secureTransport *http.Transport
+	transport       *http.Transport
+	headers         map[string]string
+}
+
+// Do makes an HTTP request, the transport is chosen by the request URL scheme.
+func (r *requester) Do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	for name, value := range r.headers {
+		req.Header.Add(name, value)
+	}
+	transport := r.transport
+	if req.URL.Scheme == "https" {
+		transport = r.secureTransport
+	}
+	return NewHTTPClient(timeout, transport).Do(req)
+}
+
+// LoadCAPool reads a certificate authority file. A nil pool is returned if the file is not set.
+func LoadCAPool(caCertFile string) (*x509.CertPool, error) {
+	if caCertFile == "" {
+		return nil, nil
+	}
+
+	caPool := x509.NewCertPool()
+	b, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return caPool, err
+	}
+
+	if !caPool.AppendCertsFromPEM(b) {
+		return caPool, errors.New("CACertFile parsing failed")
+	}
+	return caPool, nil
+}
+
+// NewSecureTransport creates a new instance of http.Transport. HTTPS certificates are not verified if the pool is nil.
+func NewSecureTransport(caPool *x509.CertPool) *http.Transport {
+	var tlsClientConfig *tls.Config
+	if caPool == nil {
+		// do HTTPS without certificate verification.
+		tlsClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	} else {
+		tlsClientConfig = &tls.Config{
+			RootCAs: caPool,
+		}
+	}
+
+	return &http.Transport{
+		TLSClientConfig: tlsClientConfig,
+	}
+}
+
+// NewHTTPClient creates a new instance of http.Client
+func NewHTTPClient(timeout time.Duration, transport *http.Transport) *http.Client {
+	client := http.Client{
+		Timeout: timeout,
+	}
+
+	if transport != nil {
+		client.Transport = transport
+	}
+
+	// go http client does not copy the headers when it follows the redirect.
+	// https://github.com/golang/go/issues/4800
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		for attr, val := range via[0].Header {
+			if _, ok := req.Header[attr]; !ok {
+				req.Header[attr] = val
+			}
+		}
+		return nil
+	}
+
+	return &client
+}
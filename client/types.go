@@ -0,0 +1,206 @@
+package client
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// Report is the cluster health report, /system/health/v1/report.
+type Report struct {
+	Units       map[string]Unit
+	Nodes       map[string]Node
+	UpdatedTime time.Time
+}
+
+// Unit for systemd unit.
+type Unit struct {
+	UnitName    string
+	Nodes       []Node `json:",omitempty"`
+	Health      int
+	Title       string
+	Timestamp   time.Time
+	PrettyName  string
+	Aggregation *UnitAggregation `json:",omitempty"`
+}
+
+// UnitAggregation shows a policy applied to compute a unit cluster health.
+type UnitAggregation struct {
+	Policy    string  `json:"policy"`
+	Role      string  `json:"role,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+	Healthy   int     `json:"healthy"`
+	Unhealthy int     `json:"unhealthy"`
+}
+
+// Node for DC/OS node
+type Node struct {
+	Leader  bool
+	Role    string
+	IP      string
+	Host    string
+	Health  int
+	Output  map[string]string
+	Units   []Unit `json:",omitempty"`
+	MesosID string
+	Port    int    `json:",omitempty"`
+	Scheme  string `json:",omitempty"`
+
+	// NegotiatedScheme is the scheme used to pull the node.
+	NegotiatedScheme string            `json:",omitempty"`
+	Labels           map[string]string `json:",omitempty"`
+	DCOSVersion      string            `json:",omitempty"`
+	TDTVersion       string            `json:",omitempty"`
+	System           *SysMetrics       `json:",omitempty"`
+	Mesos            *MesosAgentInfo   `json:",omitempty"`
+
+	// Help is a remediation of the unhealthy units found in a knowledge base, by unit name.
+	Help map[string]string `json:",omitempty"`
+}
+
+// MesosAgentInfo is an agent metadata found by the discovery.
+type MesosAgentInfo struct {
+	RegisteredTime time.Time              `json:"registered_time"`
+	Active         bool                   `json:"active"`
+	Resources      map[string]interface{} `json:"resources,omitempty"`
+	Attributes     map[string]string      `json:"attributes,omitempty"`
+}
+
+// HealthResponse is a health report of a node, /system/health/v1.
+type HealthResponse struct {
+	Array       []UnitHealth `json:"units"`
+	System      SysMetrics   `json:"system"`
+	Hostname    string       `json:"hostname"`
+	IPAddress   string       `json:"ip"`
+	DcosVersion string       `json:"dcos_version"`
+	Role        string       `json:"node_role"`
+	MesosID     string       `json:"mesos_id"`
+	TdtVersion  string       `json:"3dt_version"`
+}
+
+// UnitHealth is a unit health on a node.
+type UnitHealth struct {
+	UnitID     string `json:"id"`
+	UnitHealth int    `json:"health"`
+	UnitOutput string `json:"output"`
+	UnitTitle  string `json:"description"`
+	Help       string `json:"help"`
+	PrettyName string `json:"name"`
+}
+
+// SysMetrics are the system metrics of a node.
+type SysMetrics struct {
+	Memory      mem.VirtualMemoryStat `json:"memory"`
+	LoadAvarage load.AvgStat          `json:"load_avarage"`
+	Partitions  []disk.PartitionStat  `json:"partitions"`
+	DiskUsage   []disk.UsageStat      `json:"disk_usage"`
+}
+
+// UnitsResponse is a unit health overview, collected from all hosts.
+type UnitsResponse struct {
+	Array []UnitResponse `json:"units"`
+	Total int            `json:"total"`
+}
+
+// UnitResponse is a unit cluster health.
+type UnitResponse struct {
+	UnitID      string           `json:"id"`
+	PrettyName  string           `json:"name"`
+	UnitHealth  int              `json:"health"`
+	UnitTitle   string           `json:"description"`
+	Aggregation *UnitAggregation `json:"aggregation,omitempty"`
+}
+
+// NodesResponse is a list of nodes.
+type NodesResponse struct {
+	Array []*NodeResponse `json:"nodes"`
+	Total int             `json:"total"`
+}
+
+// NodeResponse is a node health.
+type NodeResponse struct {
+	HostIP     string `json:"host_ip"`
+	NodeHealth int    `json:"health"`
+	NodeRole   string `json:"role"`
+}
+
+// NodeUnitResponse is a unit health on a node with the unit output.
+type NodeUnitResponse struct {
+	HostIP     string `json:"host_ip"`
+	NodeHealth int    `json:"health"`
+	NodeRole   string `json:"role"`
+	UnitOutput string `json:"output"`
+	Help       string `json:"help"`
+}
+
+// NodeSystemResponse are the system metrics of a node.
+type NodeSystemResponse struct {
+	HostIP string     `json:"host_ip"`
+	Role   string     `json:"role"`
+	System SysMetrics `json:"system"`
+}
+
+// DiagnosticsResponse is a response of the diagnostics job endpoints.
+type DiagnosticsResponse struct {
+	ResponseCode int      `json:"response_http_code"`
+	Version      int      `json:"version"`
+	Status       string   `json:"status"`
+	Errors       []string `json:"errors"`
+
+	// error response fields, set if the response code is an error.
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// CreateResponse is a response to start a diagnostics job.
+type CreateResponse struct {
+	DiagnosticsResponse
+	Extra struct {
+		LastBundleFile string `json:"bundle_name"`
+	} `json:"extra"`
+}
+
+// CreateRequest is a request to start a diagnostics job, example: {"nodes": ["all"]}
+type CreateRequest struct {
+	Version int
+	Nodes   []string
+}
+
+// BundleStatus is a diagnostics job status.
+type BundleStatus struct {
+	// job related fields
+	Running               bool     `json:"is_running"`
+	Status                string   `json:"status"`
+	Errors                []string `json:"errors"`
+	LastBundlePath        string   `json:"last_bundle_dir"`
+	JobStarted            string   `json:"job_started"`
+	JobEnded              string   `json:"job_ended"`
+	JobDuration           string   `json:"job_duration"`
+	JobProgressPercentage float32  `json:"job_progress_percentage"`
+
+	// config related fields
+	DiagnosticBundlesBaseDir                 string `json:"diagnostics_bundle_dir"`
+	DiagnosticsJobTimeoutMin                 int    `json:"diagnostics_job_timeout_min"`
+	DiagnosticsUnitsLogsSinceHours           string `json:"journald_logs_since_hours"`
+	DiagnosticsJobGetSingleURLTimeoutMinutes int    `json:"diagnostics_job_get_since_url_timeout_min"`
+	CommandExecTimeoutSec                    int    `json:"command_exec_timeout_sec"`
+
+	// metrics related
+	DiskUsedPercent float64 `json:"diagnostics_partition_disk_usage_percent"`
+}
+
+// Bundle is a diagnostics bundle available for download.
+type Bundle struct {
+	File string `json:"file_name"`
+	Size int64  `json:"file_size"`
+}
+
+// ErrorResponse is a JSON body of an error response.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}